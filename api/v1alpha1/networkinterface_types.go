@@ -38,6 +38,9 @@ type NetworkInterfaceStatus struct {
 	// LinkName is the name of the Interface
 	LinkName string `json:"linkName"`
 
+	// OriginalLinkName is the name given to the Interface by the kernel, before any rename
+	OriginalLinkName string `json:"originalLinkName,omitempty"`
+
 	// MacAddress is the mac address of the interface
 	MacAddress string `json:"macAddress"`
 
@@ -46,6 +49,51 @@ type NetworkInterfaceStatus struct {
 
 	// ParentCIDR is the parent cidr of the Address
 	ParentCIDR string `json:"parentCidr,omitempty"`
+
+	// DHCPEntryID is the ID of the reservation of the Address in the DHCP server of the Public Gateway
+	DHCPEntryID string `json:"dhcpEntryId,omitempty"`
+
+	// MTU is the effective MTU of the interface
+	MTU int32 `json:"mtu,omitempty"`
+
+	// Detach is the state of the detach of the interface from the node, while it is deleted
+	// +optional
+	Detach *NetworkInterfaceDetachStatus `json:"detach,omitempty"`
+
+	// Adoption is the address found on the link by the node before it was configured, when the PrivateNetwork adopts the addresses
+	// +optional
+	Adoption *NetworkInterfaceAdoptionStatus `json:"adoption,omitempty"`
+}
+
+// NetworkInterfaceAdoptionStatus is the address found on the link of a node, to be imported into the IPAM
+type NetworkInterfaceAdoptionStatus struct {
+	// Address is the address found on the link, with its prefix length, empty if the link had no address in the CIDR
+	// +optional
+	Address string `json:"address,omitempty"`
+}
+
+// DetachPhase is what the detach of a NetworkInterface is waiting for
+type DetachPhase string
+
+const (
+	// DetachPhaseWaitingForDrain is waiting for the node to be cordoned and drained
+	DetachPhaseWaitingForDrain DetachPhase = "WaitingForDrain"
+	// DetachPhaseWaitingForPods is waiting for the selected pods to leave the node
+	DetachPhaseWaitingForPods DetachPhase = "WaitingForPods"
+	// DetachPhaseGracePeriod is waiting for the end of the grace period
+	DetachPhaseGracePeriod DetachPhase = "GracePeriod"
+)
+
+// NetworkInterfaceDetachStatus describes what the detach of a NetworkInterface is waiting for
+type NetworkInterfaceDetachStatus struct {
+	// Phase is what the detach is waiting for
+	Phase DetachPhase `json:"phase"`
+	// Message is a human readable message about the phase
+	// +optional
+	Message string `json:"message,omitempty"`
+	// DrainedAt is the time the node was found drained, from which the grace period starts
+	// +optional
+	DrainedAt *metav1.Time `json:"drainedAt,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -17,13 +17,31 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // PrivateNetworkSpec defines the desired state of PrivateNetwork
 type PrivateNetworkSpec struct {
 	// ID is the ID of the PrivateNetwork
-	ID string `json:"id"`
+	// The private network is created by the controller if not set
+	// +optional
+	ID string `json:"id,omitempty"`
+
+	// Name is the name of the private network created by the controller
+	// Will default to the name of the object
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Tags are the tags of the private network created by the controller
+	// +optional
+	Tags []string `json:"tags,omitempty"`
+
+	// DeletionPolicy represents whether the private network is deleted from Scaleway with the object
+	// Only a private network created by the controller is deleted, never the one given with the ID
+	// +optional
+	// +kubebuilder:default:=Retain
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
 
 	// Zone is the Zone of the PrivateNetwork
 	// Will default to the SCW_DEFAULT_ZONE env variable
@@ -36,23 +54,99 @@ type PrivateNetworkSpec struct {
 	// +optional
 	Routes []PrivateNetworkRoute `json:"routes,omitempty"`
 
+	// Gateway is the Public Gateway the nodes can route their traffic through
+	// +optional
+	Gateway *PrivateNetworkGateway `json:"gateway,omitempty"`
+
+	// Detach defines how the private network is detached from a node, whether the node is removed or the PrivateNetwork deleted
+	// Will detach it as soon as the node daemon has torn down the interface if not set
+	// +optional
+	Detach *PrivateNetworkDetach `json:"detach,omitempty"`
+
 	// Masquerade represents whether the private network needs to be masqueraded
 	// +optional
 	// +kubebuilder:default:=true
 	Masquerade bool `json:"masquerade,omitempty"`
 
+	// MTU is the MTU to set on the private network interfaces
+	// Will keep the interface default if not set
+	// +optional
+	// +kubebuilder:validation:Minimum=1280
+	// +kubebuilder:validation:Maximum=8896
+	MTU int32 `json:"mtu,omitempty"`
+
+	// LinkName is a template for the name of the private network interfaces on the nodes, e.g. pn-{{.Name}}
+	// The template can use the Name and ID of the PrivateNetwork, and the result must not exceed 15 characters
+	// Will keep the kernel name if not set
+	// +optional
+	LinkName string `json:"linkName,omitempty"`
+
+	// CredentialsRef references a Secret with the Scaleway credentials to use for this PrivateNetwork,
+	// with the SCW_ACCESS_KEY, SCW_SECRET_KEY, SCW_DEFAULT_PROJECT_ID and SCW_DEFAULT_ORGANIZATION_ID keys
+	// The Secret must be in the namespace of the controller, or the one given with --credentials-namespace
+	// Will default to the credentials of the controller if not set
+	// +optional
+	CredentialsRef *SecretReference `json:"credentialsRef,omitempty"`
+
 	// CIDR is the CIDR of the PrivateNetwork
 	// deprecated
 	CIDR string `json:"cidr,omitempty"`
 }
 
+// SecretReference references a Secret
+type SecretReference struct {
+	// Name is the name of the Secret
+	Name string `json:"name"`
+	// Namespace is the namespace of the Secret
+	Namespace string `json:"namespace"`
+}
+
+// +kubebuilder:validation:Enum=Retain;Delete
+// DeletionPolicy represents what happens to the Scaleway private network when the PrivateNetwork is deleted
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyRetain keeps the Scaleway private network
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicyDelete deletes the Scaleway private network
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+)
+
 // PrivateNetworkRoute defines a route from the PrivateNetwork
 type PrivateNetworkRoute struct {
 	To  string `json:"to"`
 	Via string `json:"via"`
 }
 
-// +kubebuilder:validation:Enum=DHCP;Static
+// PrivateNetworkGateway defines the routes through a Public Gateway attached to the PrivateNetwork
+type PrivateNetworkGateway struct {
+	// ID is the ID of the Public Gateway
+	ID string `json:"id"`
+	// DefaultRoute represents whether the default route of the nodes goes through the Public Gateway
+	// Meant for nodes without a public IP, the nodes already having a default route through another link are left out
+	// +optional
+	DefaultRoute bool `json:"defaultRoute,omitempty"`
+	// Routes are the destinations routed through the Public Gateway
+	// +optional
+	Routes []string `json:"routes,omitempty"`
+}
+
+// PrivateNetworkDetach defines what the detach of the private network from a node waits for
+type PrivateNetworkDetach struct {
+	// WaitForDrain represents whether the detach of a node being removed waits for it to be cordoned and drained
+	// With PodSelector, the detach waits for either of them
+	// +optional
+	WaitForDrain bool `json:"waitForDrain,omitempty"`
+	// PodSelector selects the pods the detach waits for to leave the node, in all the namespaces
+	// With WaitForDrain, the detach waits for either of them
+	// +optional
+	PodSelector *metav1.LabelSelector `json:"podSelector,omitempty"`
+	// GracePeriod is the delay between the node being drained, or deleted, and the detach
+	// +optional
+	GracePeriod *metav1.Duration `json:"gracePeriod,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=DHCP;Static;Subnet;Reserved
 // IPAMType represents a type of IPAM
 type IPAMType string
 
@@ -61,13 +155,20 @@ const (
 	IPAMTypeDHCP IPAMType = "DHCP"
 	// IPAMTypeStatic represents the static IPAM type
 	IPAMTypeStatic IPAMType = "Static"
+	// IPAMTypeSubnet represents the static IPAM type, with the CIDR derived from the subnet of the Scaleway private network
+	IPAMTypeSubnet IPAMType = "Subnet"
+	// IPAMTypeReserved represents the static IPAM type, with the addresses reserved in the DHCP server of the Public Gateway
+	IPAMTypeReserved IPAMType = "Reserved"
 )
 
 type PrivateNetworkIPAMStatic struct {
 	// CIDR represents the CIDR associated to this private network
-	CIDR string `json:"cidr"`
+	// Required with the Static and Reserved IPAM types, and ignored with the Subnet one
+	// +optional
+	CIDR string `json:"cidr,omitempty"`
 	// AvailableRanges allows to restrict which ranges of addresses should be used when choosing an IP address
 	// Defaults to the whole CIDR
+	// With the Subnet IPAM type, the ranges must be within the subnet
 	AvailableRanges []string `json:"availableRanges,omitempty"`
 }
 
@@ -75,16 +176,85 @@ type PrivateNetworkIPAMStatic struct {
 type PrivateNetworkIPAM struct {
 	Type   IPAMType                  `json:"type"`
 	Static *PrivateNetworkIPAMStatic `json:"static,omitempty"`
+	// Adopt imports the address already configured on the link of a node into the IPAM, instead of allocating a new one,
+	// to install the controller into a running cluster without changing the addresses of the nodes
+	// Only used with the Static, Subnet and Reserved IPAM types
+	// +optional
+	Adopt bool `json:"adopt,omitempty"`
+}
+
+// PrivateNetworkConditionType is a type of condition of a PrivateNetwork
+type PrivateNetworkConditionType string
+
+const (
+	// PrivateNetworkReady represents whether the PrivateNetwork is attached to all the nodes
+	PrivateNetworkReady PrivateNetworkConditionType = "Ready"
+)
+
+// PrivateNetworkCondition describes the state of a PrivateNetwork
+type PrivateNetworkCondition struct {
+	// Type is the type of the condition
+	Type PrivateNetworkConditionType `json:"type"`
+	// Status is the status of the condition, one of True, False, Unknown
+	Status corev1.ConditionStatus `json:"status"`
+	// LastTransitionTime is the last time the condition changed of status
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a CamelCase reason for the last transition
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable message about the last transition
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// PrivateNetworkNodeError describes why a PrivateNetwork could not be attached to a node
+type PrivateNetworkNodeError struct {
+	// Node is the name of the node
+	Node string `json:"node"`
+	// Reason is a CamelCase reason for the error
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable message about the error
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // PrivateNetworkStatus defines the observed state of PrivateNetwork
 type PrivateNetworkStatus struct {
+	// ID is the ID of the Scaleway private network, given in the spec or created by the controller
+	// +optional
+	ID string `json:"id,omitempty"`
+	// Name is the name of the Scaleway private network
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Tags are the tags of the Scaleway private network
+	// +optional
+	Tags []string `json:"tags,omitempty"`
+	// Subnets are the subnets of the Scaleway private network
+	// +optional
+	Subnets []string `json:"subnets,omitempty"`
+	// CIDR is the CIDR of the Subnet IPAM, derived from the subnet of the Scaleway private network
+	// +optional
+	CIDR string `json:"cidr,omitempty"`
+	// GatewayAddress is the address of the Public Gateway in the private network
+	// +optional
+	GatewayAddress string `json:"gatewayAddress,omitempty"`
+	// CreatedAt is the creation date of the Scaleway private network
+	// +optional
+	CreatedAt *metav1.Time `json:"createdAt,omitempty"`
+	// Conditions are the current conditions of the PrivateNetwork
+	// +optional
+	Conditions []PrivateNetworkCondition `json:"conditions,omitempty"`
+	// FailedNodes are the nodes the PrivateNetwork could not be attached to
+	// +optional
+	FailedNodes []PrivateNetworkNodeError `json:"failedNodes,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:scope=Cluster,shortName=pn;privnet;privatenet;privatenetwork
-// +kubebuilder:printcolumn:name="id",type="string",JSONPath=".spec.id"
+// +kubebuilder:printcolumn:name="id",type="string",JSONPath=".status.id"
 // +kubebuilder:printcolumn:name="ipam type",type="string",JSONPath=".spec.ipam.type"
 
 // PrivateNetwork is the Schema for the privatenetworks API
@@ -96,6 +266,64 @@ type PrivateNetwork struct {
 	Status PrivateNetworkStatus `json:"status,omitempty"`
 }
 
+// PrivateNetworkID returns the ID of the Scaleway private network, given in the spec or created by the controller
+func (pn *PrivateNetwork) PrivateNetworkID() string {
+	if pn.Spec.ID != "" {
+		return pn.Spec.ID
+	}
+	return pn.Status.ID
+}
+
+// IsStaticIPAM returns whether the addresses of the PrivateNetwork are allocated by the controller from a static CIDR
+func (pn *PrivateNetwork) IsStaticIPAM() bool {
+	if pn.Spec.IPAM == nil {
+		return false
+	}
+	switch pn.Spec.IPAM.Type {
+	case IPAMTypeStatic, IPAMTypeSubnet, IPAMTypeReserved:
+		return true
+	}
+	return false
+}
+
+// AdoptsAddresses returns whether the addresses configured on the links of the nodes are imported into the IPAM
+func (pn *PrivateNetwork) AdoptsAddresses() bool {
+	return pn.IsStaticIPAM() && pn.Spec.IPAM.Adopt
+}
+
+// StaticCIDR returns the CIDR of the static IPAM, given in the spec or derived from the subnet of the private network
+func (pn *PrivateNetwork) StaticCIDR() string {
+	if pn.Spec.IPAM == nil {
+		return ""
+	}
+	switch pn.Spec.IPAM.Type {
+	case IPAMTypeStatic, IPAMTypeReserved:
+		if pn.Spec.IPAM.Static != nil {
+			return pn.Spec.IPAM.Static.CIDR
+		}
+	case IPAMTypeSubnet:
+		return pn.Status.CIDR
+	}
+	return ""
+}
+
+// IPAMRanges returns the IPAM prefixes used by the PrivateNetwork
+func (pn *PrivateNetwork) IPAMRanges() []string {
+	if pn.IsStaticIPAM() {
+		if pn.Spec.IPAM.Static != nil && len(pn.Spec.IPAM.Static.AvailableRanges) != 0 {
+			return pn.Spec.IPAM.Static.AvailableRanges
+		}
+		if cidr := pn.StaticCIDR(); cidr != "" {
+			return []string{cidr}
+		}
+		return nil
+	}
+	if pn.Spec.CIDR != "" {
+		return []string{pn.Spec.CIDR}
+	}
+	return nil
+}
+
 // +kubebuilder:object:root=true
 
 // PrivateNetworkList contains a list of PrivateNetwork
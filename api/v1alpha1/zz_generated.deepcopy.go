@@ -21,6 +21,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -30,7 +31,7 @@ func (in *NetworkInterface) DeepCopyInto(out *NetworkInterface) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkInterface.
@@ -51,6 +52,40 @@ func (in *NetworkInterface) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkInterfaceAdoptionStatus) DeepCopyInto(out *NetworkInterfaceAdoptionStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkInterfaceAdoptionStatus.
+func (in *NetworkInterfaceAdoptionStatus) DeepCopy() *NetworkInterfaceAdoptionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkInterfaceAdoptionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkInterfaceDetachStatus) DeepCopyInto(out *NetworkInterfaceDetachStatus) {
+	*out = *in
+	if in.DrainedAt != nil {
+		in, out := &in.DrainedAt, &out.DrainedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkInterfaceDetachStatus.
+func (in *NetworkInterfaceDetachStatus) DeepCopy() *NetworkInterfaceDetachStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkInterfaceDetachStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkInterfaceList) DeepCopyInto(out *NetworkInterfaceList) {
 	*out = *in
@@ -101,6 +136,16 @@ func (in *NetworkInterfaceSpec) DeepCopy() *NetworkInterfaceSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkInterfaceStatus) DeepCopyInto(out *NetworkInterfaceStatus) {
 	*out = *in
+	if in.Detach != nil {
+		in, out := &in.Detach, &out.Detach
+		*out = new(NetworkInterfaceDetachStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Adoption != nil {
+		in, out := &in.Adoption, &out.Adoption
+		*out = new(NetworkInterfaceAdoptionStatus)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkInterfaceStatus.
@@ -119,7 +164,7 @@ func (in *PrivateNetwork) DeepCopyInto(out *PrivateNetwork) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetwork.
@@ -140,6 +185,67 @@ func (in *PrivateNetwork) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateNetworkCondition) DeepCopyInto(out *PrivateNetworkCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkCondition.
+func (in *PrivateNetworkCondition) DeepCopy() *PrivateNetworkCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateNetworkCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateNetworkDetach) DeepCopyInto(out *PrivateNetworkDetach) {
+	*out = *in
+	if in.PodSelector != nil {
+		in, out := &in.PodSelector, &out.PodSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GracePeriod != nil {
+		in, out := &in.GracePeriod, &out.GracePeriod
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkDetach.
+func (in *PrivateNetworkDetach) DeepCopy() *PrivateNetworkDetach {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateNetworkDetach)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateNetworkGateway) DeepCopyInto(out *PrivateNetworkGateway) {
+	*out = *in
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkGateway.
+func (in *PrivateNetworkGateway) DeepCopy() *PrivateNetworkGateway {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateNetworkGateway)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PrivateNetworkIPAM) DeepCopyInto(out *PrivateNetworkIPAM) {
 	*out = *in
@@ -212,6 +318,21 @@ func (in *PrivateNetworkList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateNetworkNodeError) DeepCopyInto(out *PrivateNetworkNodeError) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkNodeError.
+func (in *PrivateNetworkNodeError) DeepCopy() *PrivateNetworkNodeError {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateNetworkNodeError)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PrivateNetworkRoute) DeepCopyInto(out *PrivateNetworkRoute) {
 	*out = *in
@@ -230,6 +351,11 @@ func (in *PrivateNetworkRoute) DeepCopy() *PrivateNetworkRoute {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PrivateNetworkSpec) DeepCopyInto(out *PrivateNetworkSpec) {
 	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.IPAM != nil {
 		in, out := &in.IPAM, &out.IPAM
 		*out = new(PrivateNetworkIPAM)
@@ -240,6 +366,21 @@ func (in *PrivateNetworkSpec) DeepCopyInto(out *PrivateNetworkSpec) {
 		*out = make([]PrivateNetworkRoute, len(*in))
 		copy(*out, *in)
 	}
+	if in.Gateway != nil {
+		in, out := &in.Gateway, &out.Gateway
+		*out = new(PrivateNetworkGateway)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Detach != nil {
+		in, out := &in.Detach, &out.Detach
+		*out = new(PrivateNetworkDetach)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CredentialsRef != nil {
+		in, out := &in.CredentialsRef, &out.CredentialsRef
+		*out = new(SecretReference)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkSpec.
@@ -255,6 +396,32 @@ func (in *PrivateNetworkSpec) DeepCopy() *PrivateNetworkSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PrivateNetworkStatus) DeepCopyInto(out *PrivateNetworkStatus) {
 	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CreatedAt != nil {
+		in, out := &in.CreatedAt, &out.CreatedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]PrivateNetworkCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.FailedNodes != nil {
+		in, out := &in.FailedNodes, &out.FailedNodes
+		*out = make([]PrivateNetworkNodeError, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkStatus.
@@ -266,3 +433,18 @@ func (in *PrivateNetworkStatus) DeepCopy() *PrivateNetworkStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretReference) DeepCopyInto(out *SecretReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretReference.
+func (in *SecretReference) DeepCopy() *SecretReference {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretReference)
+	in.DeepCopyInto(out)
+	return out
+}
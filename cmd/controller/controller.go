@@ -17,13 +17,15 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	goipam "github.com/metal-stack/go-ipam"
-	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
-	vpc "github.com/scaleway/scaleway-sdk-go/api/vpc/v1"
 	"github.com/scaleway/scaleway-sdk-go/scw"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -32,10 +34,15 @@ import (
 	"k8s.io/klog"
 	"k8s.io/klog/klogr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
 	"github.com/Sh4d1/scaleway-k8s-vpc/controllers"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/options"
 	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/ipam"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/scwapi"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/scwmetrics"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -43,9 +50,10 @@ var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
 
-	defaultCmName        = "scaleway-k8s-vpc-ipam"
-	defaultCmNamespace   = "default"
-	cacheUpdateFrequency = time.Minute * 20
+	defaultCmName      = "scaleway-k8s-vpc-ipam"
+	defaultCmNamespace = "default"
+	defaultSyncPeriod  = time.Minute * 20
+	scwClientTimeout   = time.Second * 30
 )
 
 func init() {
@@ -57,8 +65,70 @@ func init() {
 
 func main() {
 	var metricsAddr string
+	var healthProbeAddr string
+	var credentialsDir string
+	var credentialsSecret string
+	var credentialsNamespace string
+	var credentialsReloadPeriod time.Duration
 	var enableLeaderElection bool
+	var serverCacheTTL time.Duration
+	var apiQPS float64
+	var apiBurst int
+	var apiMaxRetries int
+	var maxConcurrentNodes int
+	var syncTags bool
+	var clusterName string
+	var syncPeriod time.Duration
+	var privateNetworkWorkers int
+	var networkInterfaceWorkers int
+	var minBackoff time.Duration
+	var maxBackoff time.Duration
+	var gcPeriod time.Duration
+	var gcDryRun bool
+	var ipamStorage string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&healthProbeAddr, "health-probe-addr", ":8081", "The address the health probes endpoint binds to.")
+	flag.StringVar(&credentialsDir, "credentials-dir", "",
+		"The directory where the Scaleway credentials secret is mounted, the credentials are read from the environment if not set.")
+	flag.StringVar(&credentialsSecret, "credentials-secret", "",
+		"The namespace/name of the secret with the Scaleway credentials, used if --credentials-dir is not set.")
+	flag.StringVar(&credentialsNamespace, "credentials-namespace", "",
+		"The namespace of the secrets referenced by the credentialsRef of the privateNetworks, defaults to the namespace of the controller.")
+	flag.DurationVar(&credentialsReloadPeriod, "credentials-reload-period", controllers.DefaultCredentialsReloadPeriod,
+		"The period at which the Scaleway credentials are reloaded and checked.")
+	flag.DurationVar(&serverCacheTTL, "server-cache-ttl", controllers.DefaultServerCacheTTL,
+		"The duration the Scaleway servers of the nodes are cached.")
+	flag.Float64Var(&apiQPS, "scw-api-qps", scwapi.DefaultQPS,
+		"The number of requests per second allowed to the Scaleway API, shared by all the controllers.")
+	flag.IntVar(&apiBurst, "scw-api-burst", scwapi.DefaultBurst,
+		"The burst of requests allowed to the Scaleway API.")
+	flag.IntVar(&apiMaxRetries, "scw-api-max-retries", scwapi.DefaultMaxRetries,
+		"The number of retries of a throttled or failed request to the Scaleway API.")
+	flag.IntVar(&maxConcurrentNodes, "max-concurrent-nodes", controllers.DefaultMaxConcurrentNodes,
+		"The number of nodes a PrivateNetwork is attached to in parallel.")
+	flag.BoolVar(&syncTags, "sync-tags", false,
+		"Set tags on the Scaleway private networks and private NICs showing the cluster and the PrivateNetwork owning them.")
+	flag.StringVar(&clusterName, "cluster-name", "",
+		"The name of the cluster set in the tags of the Scaleway private networks and private NICs.")
+	flag.DurationVar(&syncPeriod, "sync-period", defaultSyncPeriod,
+		"The period at which all the privateNetworks and networkInterfaces are reconciled.")
+	flag.IntVar(&privateNetworkWorkers, "privatenetwork-workers", 1,
+		"The number of privateNetworks reconciled in parallel.")
+	flag.IntVar(&networkInterfaceWorkers, "networkinterface-workers", 1,
+		"The number of networkInterfaces reconciled in parallel.")
+	flag.DurationVar(&controllers.RequeueDuration, "requeue-duration", controllers.RequeueDuration,
+		"The duration after which a privateNetwork waiting for its networkInterfaces to be deleted is reconciled again.")
+	flag.DurationVar(&minBackoff, "min-backoff", options.DefaultMinBackoff,
+		"The delay before a failed reconcile is retried, doubled on each failure.")
+	flag.DurationVar(&maxBackoff, "max-backoff", options.DefaultMaxBackoff,
+		"The longest delay before a failed reconcile is retried.")
+	flag.DurationVar(&gcPeriod, "gc-period", controllers.DefaultGCPeriod,
+		"The period at which the orphaned private NICs and IPAM entries are looked for, 0 to disable.")
+	flag.BoolVar(&gcDryRun, "gc-dry-run", true,
+		"Only report the orphaned private NICs and IPAM entries instead of deleting them.")
+	flag.StringVar(&ipamStorage, "ipam-storage", ipam.StorageConfigMap,
+		"Where the IPAM prefixes are stored, configmap for a single configmap, or sharded-configmap for a configmap per prefix. "+
+			"The sharded storage migrates the prefixes of the single configmap.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
@@ -67,38 +137,81 @@ func main() {
 
 	ctrl.SetLogger(klogr.New())
 
+	if err := options.FromEnv(flag.CommandLine); err != nil {
+		setupLog.Error(err, "unable to read flags from environment")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: metricsAddr,
-		Port:               9443,
-		LeaderElection:     enableLeaderElection,
-		LeaderElectionID:   "be46b6df.scaleway.com",
+		Scheme:                 scheme,
+		MetricsBindAddress:     metricsAddr,
+		HealthProbeBindAddress: healthProbeAddr,
+		Port:                   9443,
+		LeaderElection:         enableLeaderElection,
+		LeaderElectionID:       "be46b6df.scaleway.com",
+		SyncPeriod:             &syncPeriod,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	scwClient, err := scw.NewClient(
-		scw.WithEnv(),
+	apiTransport := scwapi.NewTransport(scwmetrics.NewTransport(nil), apiQPS, apiBurst, apiMaxRetries)
+	scwOptions := []scw.ClientOption{
 		scw.WithUserAgent("scaleway-k8s-vpc"),
-	)
-	if err != nil {
-		setupLog.Error(err, "unable to init scaleway client")
+		scw.WithHTTPClient(&http.Client{
+			Timeout:   scwClientTimeout,
+			Transport: apiTransport,
+		}),
 	}
-
-	stopCh := ctrl.SetupSignalHandler()
-
 	cmNamespace := os.Getenv("CONFIGMAP_NAMESPACE")
 	if cmNamespace == "" {
 		cmNamespace = defaultCmNamespace
 	}
+	if credentialsNamespace == "" {
+		credentialsNamespace = cmNamespace
+	}
+	// the secrets are read from the API server, a cache would hold all the secrets of the cluster
+	apis := controllers.NewAPIsCache(mgr.GetAPIReader(), credentialsNamespace, serverCacheTTL, scwOptions...)
+
+	secretName := types.NamespacedName{}
+	if credentialsSecret != "" {
+		parts := strings.SplitN(credentialsSecret, "/", 2)
+		if len(parts) != 2 {
+			setupLog.Error(fmt.Errorf("expected namespace/name, got %s", credentialsSecret), "invalid credentials secret")
+			os.Exit(1)
+		}
+		secretName = types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+	}
+	credentials := &controllers.CredentialsReloader{
+		Reader: mgr.GetAPIReader(),
+		Log:    ctrl.Log.WithName("credentials"),
+		APIs:   apis,
+		Dir:    credentialsDir,
+		Secret: secretName,
+		Period: credentialsReloadPeriod,
+	}
+	// the controllers are started even without valid credentials, the readiness fails until they are loaded
+	if err := credentials.Load(context.Background()); err != nil {
+		setupLog.Error(err, "unable to load scaleway credentials")
+	}
+	if err := mgr.Add(credentials); err != nil {
+		setupLog.Error(err, "unable to add credentials reloader")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("credentials", credentials.Checker()); err != nil {
+		setupLog.Error(err, "unable to add readiness check")
+		os.Exit(1)
+	}
+
+	stopCh := ctrl.SetupSignalHandler()
+
 	cmName := os.Getenv("CONFIGMAP_NAME")
 	if cmName == "" {
 		cmName = defaultCmName
 	}
 
-	cmIPAM, err := ipam.NewConfigMapIPAM(types.NamespacedName{
+	cmIPAM, err := ipam.NewStorage(ipamStorage, types.NamespacedName{
 		Name:      cmName,
 		Namespace: cmNamespace,
 	}, stopCh)
@@ -106,31 +219,89 @@ func main() {
 		setupLog.Error(err, "error creating ipam storage")
 		os.Exit(1)
 	}
-	ipam := goipam.NewWithStorage(cmIPAM)
+	if sharded, ok := cmIPAM.(*ipam.ShardedConfigMapIPAM); ok {
+		err := sharded.MigrateFrom(types.NamespacedName{
+			Name:      cmName,
+			Namespace: cmNamespace,
+		})
+		if err != nil {
+			setupLog.Error(err, "error migrating ipam storage")
+			os.Exit(1)
+		}
+	}
+	ipamer := ipam.NewLocked(goipam.NewWithStorage(cmIPAM))
 
-	if err = (&controllers.PrivateNetworkReconciler{
-		Client:      mgr.GetClient(),
-		Log:         ctrl.Log.WithName("controllers").WithName("PrivateNetwork"),
-		Scheme:      mgr.GetScheme(),
-		IPAM:        ipam,
-		InstanceAPI: instance.NewAPI(scwClient),
-		VpcAPI:      vpc.NewAPI(scwClient),
-	}).SetupWithManager(mgr); err != nil {
+	privateNetworkReconciler := &controllers.PrivateNetworkReconciler{
+		Client:             mgr.GetClient(),
+		Reader:             mgr.GetAPIReader(),
+		Log:                ctrl.Log.WithName("controllers").WithName("PrivateNetwork"),
+		Scheme:             mgr.GetScheme(),
+		IPAM:               ipamer,
+		APIs:               apis,
+		APITransport:       apiTransport,
+		Recorder:           mgr.GetEventRecorderFor("scaleway-k8s-vpc-controller"),
+		MaxConcurrentNodes: maxConcurrentNodes,
+		SyncTags:           syncTags,
+		ClusterName:        clusterName,
+	}
+	if err = privateNetworkReconciler.SetupWithManager(mgr, controller.Options{
+		MaxConcurrentReconciles: privateNetworkWorkers,
+		RateLimiter:             options.RateLimiter(minBackoff, maxBackoff),
+	}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PrivateNetwork")
 		os.Exit(1)
 	}
+	if err = (&controllers.NodeReconciler{
+		Client:          mgr.GetClient(),
+		Log:             ctrl.Log.WithName("controllers").WithName("Node"),
+		PrivateNetworks: privateNetworkReconciler,
+	}).SetupWithManager(mgr, controller.Options{
+		MaxConcurrentReconciles: privateNetworkWorkers,
+		RateLimiter:             options.RateLimiter(minBackoff, maxBackoff),
+	}); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Node")
+		os.Exit(1)
+	}
 	if err = (&controllers.NetworkInterfaceReconciler{
-		Client:      mgr.GetClient(),
-		Log:         ctrl.Log.WithName("controllers").WithName("NetworkInterface"),
-		Scheme:      mgr.GetScheme(),
-		IPAM:        ipam,
-		InstanceAPI: instance.NewAPI(scwClient),
-	}).SetupWithManager(mgr); err != nil {
+		Client:       mgr.GetClient(),
+		Reader:       mgr.GetAPIReader(),
+		Log:          ctrl.Log.WithName("controllers").WithName("NetworkInterface"),
+		Scheme:       mgr.GetScheme(),
+		IPAM:         ipamer,
+		APIs:         apis,
+		APITransport: apiTransport,
+		Recorder:     mgr.GetEventRecorderFor("scaleway-k8s-vpc-controller"),
+	}).SetupWithManager(mgr, controller.Options{
+		MaxConcurrentReconciles: networkInterfaceWorkers,
+		RateLimiter:             options.RateLimiter(minBackoff, maxBackoff),
+	}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NetworkInterface")
 		os.Exit(1)
 	}
 	// +kubebuilder:scaffold:builder
 
+	if gcPeriod > 0 {
+		if err := mgr.Add(&controllers.GarbageCollector{
+			Client:   mgr.GetClient(),
+			Log:      ctrl.Log.WithName("gc"),
+			APIs:     apis,
+			IPAM:     ipamer,
+			Storage:  cmIPAM,
+			Recorder: mgr.GetEventRecorderFor("scaleway-k8s-vpc-controller"),
+			Period:   gcPeriod,
+			DryRun:   gcDryRun,
+		}); err != nil {
+			setupLog.Error(err, "unable to add garbage collector")
+			os.Exit(1)
+		}
+	}
+
+	metrics.Registry.MustRegister(&controllers.IPAMCollector{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("metrics").WithName("IPAM"),
+		IPAM:   ipamer,
+	})
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(stopCh); err != nil {
 		setupLog.Error(err, "problem running manager")
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/ipam"
+)
+
+// IssueKind is a kind of inconsistency between the IPAM storage and the networkInterfaces
+type IssueKind string
+
+const (
+	// IssueAllocatedNotUsed is an address acquired in the IPAM without networkInterface
+	IssueAllocatedNotUsed IssueKind = "AllocatedNotUsed"
+	// IssueUsedNotAllocated is an address of a networkInterface not acquired in the IPAM
+	IssueUsedNotAllocated IssueKind = "UsedNotAllocated"
+	// IssueDuplicate is an address used by several networkInterfaces
+	IssueDuplicate IssueKind = "Duplicate"
+	// IssueOrphanedPrefix is a prefix of the IPAM without privateNetwork
+	IssueOrphanedPrefix IssueKind = "OrphanedPrefix"
+)
+
+// Issue is an inconsistency between the IPAM storage and the networkInterfaces
+type Issue struct {
+	Kind              IssueKind `json:"kind"`
+	PrivateNetwork    string    `json:"privateNetwork,omitempty"`
+	CIDR              string    `json:"cidr,omitempty"`
+	Address           string    `json:"address,omitempty"`
+	NetworkInterfaces []string  `json:"networkInterfaces,omitempty"`
+}
+
+// Report is the result of a check
+type Report struct {
+	Issues []Issue `json:"issues"`
+}
+
+// check compares the dump of the IPAM storage with the addresses of the networkInterfaces
+func check(c client.Client, dump *ipam.Dump) (*Report, error) {
+	ctx := context.Background()
+
+	pnsList := &vpcv1alpha1.PrivateNetworkList{}
+	err := c.List(ctx, pnsList)
+	if err != nil {
+		return nil, fmt.Errorf("could not list privateNetworks: %w", err)
+	}
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err = c.List(ctx, nicsList)
+	if err != nil {
+		return nil, fmt.Errorf("could not list networkInterfaces: %w", err)
+	}
+
+	pnsByName := make(map[string]*vpcv1alpha1.PrivateNetwork)
+	pnsByCIDR := make(map[string]*vpcv1alpha1.PrivateNetwork)
+	for i := range pnsList.Items {
+		pnsByName[pnsList.Items[i].Name] = &pnsList.Items[i]
+		for _, cidr := range pnsList.Items[i].IPAMRanges() {
+			pnsByCIDR[cidr] = &pnsList.Items[i]
+		}
+	}
+
+	allocated := make(map[string]bool)
+	for _, prefix := range dump.Prefixes {
+		for _, ip := range prefix.IPs {
+			allocated[prefix.CIDR+"/"+ip] = true
+		}
+	}
+
+	report := &Report{
+		Issues: []Issue{},
+	}
+
+	// the networkInterfaces using each address of each privateNetwork
+	users := make(map[string][]string)
+	for _, nic := range nicsList.Items {
+		pnName := nic.Labels[constants.PrivateNetworkLabel]
+		pn, ok := pnsByName[pnName]
+		if !ok {
+			continue
+		}
+
+		address, cidr := nic.Status.Address, nic.Status.ParentCIDR
+		if !pn.IsStaticIPAM() {
+			// the deprecated addresses allocated by the privateNetwork in spec.cidr
+			address, cidr = nic.Spec.Address, pn.Spec.CIDR
+		} else if cidr == "" {
+			cidr = pn.StaticCIDR()
+		}
+		if address == "" || cidr == "" {
+			continue
+		}
+		ip := strings.Split(address, "/")[0]
+		users[pnName+"/"+ip] = append(users[pnName+"/"+ip], nic.Name)
+
+		if !allocated[cidr+"/"+ip] {
+			report.Issues = append(report.Issues, Issue{
+				Kind:              IssueUsedNotAllocated,
+				PrivateNetwork:    pnName,
+				CIDR:              cidr,
+				Address:           ip,
+				NetworkInterfaces: []string{nic.Name},
+			})
+		}
+	}
+
+	// the address of the Public Gateway is acquired by the privateNetwork
+	gateways := make(map[string]bool)
+	for _, pn := range pnsList.Items {
+		if pn.Status.GatewayAddress != "" {
+			gateways[pn.Name+"/"+pn.Status.GatewayAddress] = true
+		}
+	}
+
+	for key, nics := range users {
+		if len(nics) < 2 {
+			continue
+		}
+		parts := strings.SplitN(key, "/", 2)
+		sort.Strings(nics)
+		report.Issues = append(report.Issues, Issue{
+			Kind:              IssueDuplicate,
+			PrivateNetwork:    parts[0],
+			Address:           parts[1],
+			NetworkInterfaces: nics,
+		})
+	}
+
+	for _, prefix := range dump.Prefixes {
+		pn, ok := pnsByCIDR[prefix.CIDR]
+		if !ok {
+			report.Issues = append(report.Issues, Issue{
+				Kind: IssueOrphanedPrefix,
+				CIDR: prefix.CIDR,
+			})
+			continue
+		}
+		for _, ip := range prefix.IPs {
+			if len(users[pn.Name+"/"+ip]) == 0 && !gateways[pn.Name+"/"+ip] {
+				report.Issues = append(report.Issues, Issue{
+					Kind:           IssueAllocatedNotUsed,
+					PrivateNetwork: pn.Name,
+					CIDR:           prefix.CIDR,
+					Address:        ip,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		if report.Issues[i].Kind != report.Issues[j].Kind {
+			return report.Issues[i].Kind < report.Issues[j].Kind
+		}
+		return report.Issues[i].Address < report.Issues[j].Address
+	})
+	return report, nil
+}
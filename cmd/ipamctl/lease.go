@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultLeaderElectionID is the leader election ID of the controller
+const defaultLeaderElectionID = "be46b6df.scaleway.com"
+
+// leaseHolder returns the identity of the controller holding the leader election lock name at now,
+// empty if the lock doesn't exist or has expired
+func leaseHolder(c client.Reader, name types.NamespacedName, now time.Time) (string, error) {
+	cm := &corev1.ConfigMap{}
+	err := c.Get(context.Background(), name, cm)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	annotation, ok := cm.Annotations[resourcelock.LeaderElectionRecordAnnotationKey]
+	if !ok {
+		return "", nil
+	}
+	record := resourcelock.LeaderElectionRecord{}
+	err = json.Unmarshal([]byte(annotation), &record)
+	if err != nil {
+		return "", fmt.Errorf("invalid leader election record in configmap %s: %w", name, err)
+	}
+
+	expiresAt := record.RenewTime.Add(time.Duration(record.LeaseDurationSeconds) * time.Second)
+	if record.HolderIdentity == "" || !now.Before(expiresAt) {
+		return "", nil
+	}
+	return record.HolderIdentity, nil
+}
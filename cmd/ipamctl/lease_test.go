@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestLeaseHolder(t *testing.T) {
+	name := types.NamespacedName{Namespace: "system", Name: defaultLeaderElectionID}
+	now := time.Now()
+	lock := func(annotation string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   name.Namespace,
+			Name:        name.Name,
+			Annotations: map[string]string{resourcelock.LeaderElectionRecordAnnotationKey: annotation},
+		}}
+	}
+	record := func(holder string, renewedAgo time.Duration) string {
+		return `{"holderIdentity":"` + holder + `","leaseDurationSeconds":15,"renewTime":"` + now.Add(-renewedAgo).UTC().Format(time.RFC3339) + `"}`
+	}
+
+	tests := []struct {
+		name     string
+		objs     []runtime.Object
+		expected string
+		err      bool
+	}{
+		{
+			name: "no lock",
+		},
+		{
+			name:     "held",
+			objs:     []runtime.Object{lock(record("controller-1", time.Second*5))},
+			expected: "controller-1",
+		},
+		{
+			name: "expired",
+			objs: []runtime.Object{lock(record("controller-1", time.Minute))},
+		},
+		{
+			name: "released",
+			objs: []runtime.Object{lock(record("", time.Second))},
+		},
+		{
+			name: "invalid record",
+			objs: []runtime.Object{lock("{")},
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme, tt.objs...)
+			holder, err := leaseHolder(c, name, now)
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if holder != tt.expected {
+				t.Errorf("expected holder %q, got %q", tt.expected, holder)
+			}
+		})
+	}
+}
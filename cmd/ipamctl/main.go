@@ -0,0 +1,211 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// ipamctl dumps, checks and imports the content of the IPAM storage of the controller
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	goipam "github.com/metal-stack/go-ipam"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/ipam"
+)
+
+var (
+	scheme = runtime.NewScheme()
+
+	defaultCmName      = "scaleway-k8s-vpc-ipam"
+	defaultCmNamespace = "default"
+)
+
+func init() {
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	_ = vpcv1alpha1.AddToScheme(scheme)
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: %s [flags] <command>
+
+Commands:
+  dump    print the prefixes and the addresses of the IPAM storage
+  check   check the IPAM storage against the networkInterfaces, exits with 1 if issues are found
+  import  acquire the prefixes and the addresses of a dump, given with --file, in the IPAM storage
+
+Flags:
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var configMap string
+	var output string
+	var file string
+	var storageKind string
+	var leaderElectionID string
+	var force bool
+	flag.StringVar(&configMap, "configmap", "",
+		"The namespace/name of the IPAM configmap, defaults to the CONFIGMAP_NAMESPACE and CONFIGMAP_NAME environment variables of the controller.")
+	flag.StringVar(&storageKind, "ipam-storage", ipam.StorageConfigMap,
+		"The IPAM storage of the controller, configmap or sharded-configmap.")
+	flag.StringVar(&output, "output", "yaml", "The output format, json or yaml.")
+	flag.StringVar(&file, "file", "", "The dump to import, - for the standard input.")
+	flag.StringVar(&leaderElectionID, "leader-election-id", defaultLeaderElectionID,
+		"The leader election ID of the controller, in the namespace of the configmap. The import is refused while the controller holds it.")
+	flag.BoolVar(&force, "force", false, "Import even if the controller is running.")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if output != "json" && output != "yaml" {
+		exitOnError(fmt.Errorf("unknown output format %s", output), "invalid flags")
+	}
+
+	cmName, err := configMapName(configMap)
+	exitOnError(err, "invalid configmap")
+
+	switch flag.Arg(0) {
+	case "dump":
+		storage, err := ipam.OpenStorage(storageKind, cmName)
+		exitOnError(err, "unable to open ipam storage")
+		dump, err := ipam.DumpStorage(storage)
+		exitOnError(err, "unable to dump ipam storage")
+		exitOnError(printOutput(output, dump), "unable to print dump")
+	case "check":
+		c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+		exitOnError(err, "unable to create client")
+		storage, err := ipam.OpenStorage(storageKind, cmName)
+		exitOnError(err, "unable to open ipam storage")
+		dump, err := ipam.DumpStorage(storage)
+		exitOnError(err, "unable to dump ipam storage")
+		report, err := check(c, dump)
+		exitOnError(err, "unable to check ipam storage")
+		exitOnError(printOutput(output, report), "unable to print report")
+		if len(report.Issues) != 0 {
+			os.Exit(1)
+		}
+	case "import":
+		if file == "" {
+			exitOnError(fmt.Errorf("--file is required"), "invalid flags")
+		}
+		dump, err := readDump(file)
+		exitOnError(err, "unable to read dump")
+		if !force {
+			c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+			exitOnError(err, "unable to create client")
+			holder, err := leaseHolder(c, types.NamespacedName{Namespace: cmName.Namespace, Name: leaderElectionID}, time.Now())
+			exitOnError(err, "unable to check the leader election lock")
+			if holder != "" {
+				exitOnError(fmt.Errorf("the controller %s holds the leader election lock %s, stop it or use --force", holder, leaderElectionID), "refusing to import")
+			}
+		}
+
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+		storage, err := ipam.NewStorage(storageKind, cmName, stopCh)
+		exitOnError(err, "unable to create ipam storage")
+		exitOnError(ipam.Import(ipam.NewLocked(goipam.NewWithStorage(storage)), storage, dump), "unable to import dump")
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// configMapName returns the name of the IPAM configmap, from the flag, or else as the controller does
+func configMapName(configMap string) (types.NamespacedName, error) {
+	if configMap != "" {
+		parts := strings.SplitN(configMap, "/", 2)
+		if len(parts) != 2 {
+			return types.NamespacedName{}, fmt.Errorf("expected namespace/name, got %s", configMap)
+		}
+		return types.NamespacedName{Namespace: parts[0], Name: parts[1]}, nil
+	}
+
+	name := types.NamespacedName{
+		Namespace: os.Getenv("CONFIGMAP_NAMESPACE"),
+		Name:      os.Getenv("CONFIGMAP_NAME"),
+	}
+	if name.Namespace == "" {
+		name.Namespace = defaultCmNamespace
+	}
+	if name.Name == "" {
+		name.Name = defaultCmName
+	}
+	return name, nil
+}
+
+// readDump reads a dump in JSON or YAML from file, or from the standard input if file is -
+func readDump(file string) (*ipam.Dump, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dump := &ipam.Dump{}
+	err = yaml.UnmarshalStrict(data, dump)
+	if err != nil {
+		return nil, err
+	}
+	return dump, nil
+}
+
+// printOutput prints v in the output format
+func printOutput(output string, v interface{}) error {
+	var data []byte
+	var err error
+	if output == "json" {
+		data, err = json.MarshalIndent(v, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data, err = yaml.Marshal(v)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func exitOnError(err error, msg string) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", msg, err)
+		os.Exit(1)
+	}
+}
@@ -27,8 +27,13 @@ import (
 	"k8s.io/klog"
 	"k8s.io/klog/klogr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/options"
 	"github.com/Sh4d1/scaleway-k8s-vpc/nodes"
 	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
 	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
@@ -39,7 +44,10 @@ var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
 
-	cacheUpdateFrequency = time.Minute * 20
+	defaultSyncPeriod   = time.Minute * 20
+	defaultResyncPeriod = time.Minute * 5
+
+	defaultReadinessTimeout = time.Minute * 5
 )
 
 func init() {
@@ -51,17 +59,43 @@ func init() {
 
 func main() {
 	var metricsAddr string
+	var healthProbeAddr string
+	var resyncPeriod time.Duration
+	var readinessTimeout time.Duration
+	var syncPeriod time.Duration
+	var networkInterfaceWorkers int
+	var minBackoff time.Duration
+	var maxBackoff time.Duration
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&healthProbeAddr, "health-probe-addr", ":8081", "The address the health probes endpoint binds to.")
+	flag.DurationVar(&resyncPeriod, "resync-period", defaultResyncPeriod, "The period at which all the networkInterfaces of the node are reconciled.")
+	flag.DurationVar(&readinessTimeout, "unconfigured-readiness-timeout", defaultReadinessTimeout,
+		"The duration after which the node is not ready when a networkInterface is not configured.")
+	flag.DurationVar(&syncPeriod, "sync-period", defaultSyncPeriod,
+		"The period at which the cache of the networkInterfaces and privateNetworks is resynced.")
+	flag.IntVar(&networkInterfaceWorkers, "networkinterface-workers", 1,
+		"The number of networkInterfaces reconciled in parallel.")
+	flag.DurationVar(&minBackoff, "min-backoff", options.DefaultMinBackoff,
+		"The delay before a failed reconcile is retried, doubled on each failure.")
+	flag.DurationVar(&maxBackoff, "max-backoff", options.DefaultMaxBackoff,
+		"The longest delay before a failed reconcile is retried.")
 	klog.InitFlags(nil)
 	flag.Parse()
 
 	ctrl.SetLogger(klogr.New())
 
+	if err := options.FromEnv(flag.CommandLine); err != nil {
+		setupLog.Error(err, "unable to read flags from environment")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: metricsAddr,
-		Port:               9443,
-		LeaderElection:     false,
+		Scheme:                 scheme,
+		MetricsBindAddress:     metricsAddr,
+		HealthProbeBindAddress: healthProbeAddr,
+		Port:                   9443,
+		LeaderElection:         false,
+		SyncPeriod:             &syncPeriod,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -92,6 +126,10 @@ func main() {
 		os.Exit(1)
 	}
 
+	linkEvents := make(chan event.GenericEvent)
+	tracker := nodes.NewConfigurationTracker()
+	masquerade := nodes.NewMasqueradeRules()
+
 	if err = (&nodes.NetworkInterfaceReconciler{
 		Client:      mgr.GetClient(),
 		Log:         ctrl.Log.WithName("controllers").WithName("NetworkInterface"),
@@ -99,12 +137,49 @@ func main() {
 		MetadataAPI: metadataAPI,
 		NodeName:    nodeName,
 		NICs:        nics,
-	}).SetupWithManager(mgr); err != nil {
+		LinkEvents:  linkEvents,
+		Tracker:     tracker,
+		Masquerade:  masquerade,
+		Recorder:    mgr.GetEventRecorderFor("scaleway-k8s-vpc-node"),
+	}).SetupWithManager(mgr, controller.Options{
+		MaxConcurrentReconciles: networkInterfaceWorkers,
+		RateLimiter:             options.RateLimiter(minBackoff, maxBackoff),
+	}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NetworkInterface")
 		os.Exit(1)
 	}
+
+	if err = mgr.Add(&nodes.LinkWatcher{
+		Client:       mgr.GetClient(),
+		Log:          ctrl.Log.WithName("watchers").WithName("Link"),
+		MetadataAPI:  metadataAPI,
+		NodeName:     nodeName,
+		NICs:         nics,
+		ResyncPeriod: resyncPeriod,
+		Events:       linkEvents,
+	}); err != nil {
+		setupLog.Error(err, "unable to add link watcher")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
+	metrics.Registry.MustRegister(&nodes.LinkCollector{
+		Client:     mgr.GetClient(),
+		Log:        ctrl.Log.WithName("metrics").WithName("Link"),
+		NodeName:   nodeName,
+		NICs:       nics,
+		Masquerade: masquerade,
+	})
+
+	if err := mgr.AddReadyzCheck("networkinterfaces", tracker.Checker(readinessTimeout)); err != nil {
+		setupLog.Error(err, "unable to add readiness check")
+		os.Exit(1)
+	}
+	if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to add liveness check")
+		os.Exit(1)
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
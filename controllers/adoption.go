@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	goipam "github.com/metal-stack/go-ipam"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+)
+
+// adoptAddress imports the address found on the link of the NetworkInterface into the IPAM, and returns the acquired IP and its range
+// The address must be in a range of the PrivateNetwork, with the prefix length of its CIDR, and not used by another NetworkInterface,
+// a different address is never allocated instead, as it would add a second address to the link
+func (r *NetworkInterfaceReconciler) adoptAddress(ctx context.Context, pn *vpcv1alpha1.PrivateNetwork, nic *vpcv1alpha1.NetworkInterface, address string) (*goipam.IP, string, error) {
+	ip, ipNet, err := net.ParseCIDR(address)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid address %s: %w", address, err)
+	}
+	_, cidrNet, err := net.ParseCIDR(pn.StaticCIDR())
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid CIDR %s: %w", pn.StaticCIDR(), err)
+	}
+	ones, _ := ipNet.Mask.Size()
+	cidrOnes, _ := cidrNet.Mask.Size()
+	if ones != cidrOnes {
+		return nil, "", fmt.Errorf("address %s doesn't have the prefix length of %s", address, pn.StaticCIDR())
+	}
+
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err = r.Client.List(ctx, nicsList, client.MatchingLabels{
+		constants.PrivateNetworkLabel: pn.Name,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	for _, other := range nicsList.Items {
+		if other.Name != nic.Name && other.Status.Address != "" && strings.Split(other.Status.Address, "/")[0] == ip.String() {
+			return nil, "", fmt.Errorf("address %s is already used by networkInterface %s", ip, other.Name)
+		}
+	}
+
+	cidrs := pn.IPAMRanges()
+	for _, cidr := range cidrs {
+		_, rangeNet, err := net.ParseCIDR(cidr)
+		if err != nil || !rangeNet.Contains(ip) {
+			continue
+		}
+		prefix, err := r.IPAM.NewPrefix(cidr)
+		if err != nil {
+			return nil, "", fmt.Errorf("could not create prefix %s: %w", cidr, err)
+		}
+		acquired, err := r.IPAM.AcquireSpecificIP(prefix.Cidr, ip.String())
+		if err != nil {
+			return nil, "", fmt.Errorf("could not acquire address %s in %s: %w", ip, prefix.Cidr, err)
+		}
+		// go-ipam returns no IP and no error when the address is already acquired
+		if acquired == nil {
+			return nil, "", fmt.Errorf("address %s is already acquired in %s", ip, prefix.Cidr)
+		}
+		return acquired, prefix.Cidr, nil
+	}
+	return nil, "", fmt.Errorf("address %s is not in %s", ip, strings.Join(cidrs, ", "))
+}
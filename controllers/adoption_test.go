@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	goipam "github.com/metal-stack/go-ipam"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+func TestAdoptAddress(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = vpcv1alpha1.AddToScheme(scheme)
+
+	tests := []struct {
+		name     string
+		pn       vpcv1alpha1.PrivateNetwork
+		nics     []vpcv1alpha1.NetworkInterface
+		acquired map[string][]string
+		address  string
+		ip       string
+		cidr     string
+		err      bool
+	}{
+		{
+			name:    "address in the cidr",
+			pn:      staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeStatic, "10.0.0.0/16"),
+			address: "10.0.3.4/16",
+			ip:      "10.0.3.4",
+			cidr:    "10.0.0.0/16",
+		},
+		{
+			name:    "address in an available range",
+			pn:      staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeStatic, "10.0.0.0/16", "10.0.1.0/24", "10.0.2.0/24"),
+			address: "10.0.2.4/16",
+			ip:      "10.0.2.4",
+			cidr:    "10.0.2.0/24",
+		},
+		{
+			name:    "address outside of the cidr",
+			pn:      staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeStatic, "10.0.0.0/16"),
+			address: "10.1.0.4/16",
+			err:     true,
+		},
+		{
+			name:    "address outside of the available ranges",
+			pn:      staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeStatic, "10.0.0.0/16", "10.0.1.0/24"),
+			address: "10.0.2.4/16",
+			err:     true,
+		},
+		{
+			name:    "prefix length mismatch",
+			pn:      staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeStatic, "10.0.0.0/16"),
+			address: "10.0.3.4/24",
+			err:     true,
+		},
+		{
+			name:    "invalid address",
+			pn:      staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeStatic, "10.0.0.0/16"),
+			address: "10.0.3.4",
+			err:     true,
+		},
+		{
+			name:    "address of another networkInterface",
+			pn:      staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeStatic, "10.0.0.0/16"),
+			nics:    []vpcv1alpha1.NetworkInterface{networkInterface("pn", "other", "nic-other", "10.0.3.4/16", "10.0.0.0/16")},
+			address: "10.0.3.4/16",
+			err:     true,
+		},
+		{
+			name:    "same address on another private network",
+			pn:      staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeStatic, "10.0.0.0/16"),
+			nics:    []vpcv1alpha1.NetworkInterface{networkInterface("other-pn", "other", "nic-other", "10.0.3.4/16", "10.0.0.0/16")},
+			address: "10.0.3.4/16",
+			ip:      "10.0.3.4",
+			cidr:    "10.0.0.0/16",
+		},
+		{
+			name:     "address already acquired",
+			pn:       staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeStatic, "10.0.0.0/16"),
+			acquired: map[string][]string{"10.0.0.0/16": {"10.0.3.4"}},
+			address:  "10.0.3.4/16",
+			err:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := []runtime.Object{}
+			for i := range tt.nics {
+				objs = append(objs, &tt.nics[i])
+			}
+			ipamer := goipam.New()
+			for cidr, ips := range tt.acquired {
+				_, err := ipamer.NewPrefix(cidr)
+				if err != nil {
+					t.Fatalf("could not create prefix %s: %s", cidr, err)
+				}
+				for _, ip := range ips {
+					_, err := ipamer.AcquireSpecificIP(cidr, ip)
+					if err != nil {
+						t.Fatalf("could not acquire %s: %s", ip, err)
+					}
+				}
+			}
+			r := &NetworkInterfaceReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme, objs...),
+				IPAM:   ipamer,
+			}
+			nic := networkInterface("pn", "node", "nic", "", "")
+
+			ip, cidr, err := r.adoptAddress(context.Background(), &tt.pn, &nic, tt.address)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, got %s in %s", ip.IP, cidr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if ip.IP.String() != tt.ip || cidr != tt.cidr {
+				t.Errorf("expected %s in %s, got %s in %s", tt.ip, tt.cidr, ip.IP, cidr)
+			}
+			// the address is acquired, and can't be adopted twice
+			_, _, err = r.adoptAddress(context.Background(), &tt.pn, &nic, tt.address)
+			if err == nil {
+				t.Errorf("expected an error adopting %s twice", tt.address)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/scwapi"
+)
+
+var (
+	// QuotaRequeueDuration is the requeue duration after an exceeded quota or a throttled request
+	QuotaRequeueDuration time.Duration = time.Minute * 5
+	// NotFoundRequeueDuration is the requeue duration after a Scaleway resource is not found
+	NotFoundRequeueDuration time.Duration = time.Minute * 10
+	// PermissionRequeueDuration is the requeue duration after denied credentials or permissions
+	PermissionRequeueDuration time.Duration = time.Minute * 10
+)
+
+// resultForAPIError returns the result of a reconcile failing with the Scaleway API error err
+// Transient errors are returned to be retried with the backoff of the controller, the others
+// are requeued after a fixed duration since retrying them right away won't help
+func resultForAPIError(err error, retryAfter time.Duration) (ctrl.Result, error) {
+	switch scwapi.Classify(err) {
+	case scwapi.ErrorClassQuota:
+		if retryAfter > QuotaRequeueDuration {
+			return ctrl.Result{RequeueAfter: retryAfter}, nil
+		}
+		return ctrl.Result{RequeueAfter: QuotaRequeueDuration}, nil
+	case scwapi.ErrorClassNotFound:
+		return ctrl.Result{RequeueAfter: NotFoundRequeueDuration}, nil
+	case scwapi.ErrorClassPermission:
+		return ctrl.Result{RequeueAfter: PermissionRequeueDuration}, nil
+	}
+	return ctrl.Result{}, err
+}
+
+// reasonForAPIError returns the condition reason for the Scaleway API error err
+func reasonForAPIError(err error) string {
+	switch scwapi.Classify(err) {
+	case scwapi.ErrorClassQuota:
+		return constants.ReasonAPIQuotaExceeded
+	case scwapi.ErrorClassNotFound:
+		return constants.ReasonAPINotFound
+	case scwapi.ErrorClassPermission:
+		return constants.ReasonAPIPermissionDenied
+	}
+	return constants.ReasonAPITransientError
+}
+
+// setPrivateNetworkCondition sets the condition in status, keeping its last transition time if the status didn't change
+func setPrivateNetworkCondition(status *vpcv1alpha1.PrivateNetworkStatus, condition vpcv1alpha1.PrivateNetworkCondition) {
+	for i, existing := range status.Conditions {
+		if existing.Type != condition.Type {
+			continue
+		}
+		if existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		} else {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		status.Conditions[i] = condition
+		return
+	}
+	condition.LastTransitionTime = metav1.Now()
+	status.Conditions = append(status.Conditions, condition)
+}
+
+// updateStatus applies update to the status of pn, and patches it if changed
+func (r *PrivateNetworkReconciler) updateStatus(ctx context.Context, pn *vpcv1alpha1.PrivateNetwork, update func(*vpcv1alpha1.PrivateNetworkStatus)) error {
+	original := pn.DeepCopy()
+	update(&pn.Status)
+	if equality.Semantic.DeepEqual(original.Status, pn.Status) {
+		return nil
+	}
+	return r.Status().Patch(ctx, pn, client.MergeFrom(original))
+}
+
+// setReady patches the Ready condition of pn, if changed
+func (r *PrivateNetworkReconciler) setReady(ctx context.Context, pn *vpcv1alpha1.PrivateNetwork, status corev1.ConditionStatus, reason, message string) error {
+	return r.updateStatus(ctx, pn, func(pnStatus *vpcv1alpha1.PrivateNetworkStatus) {
+		setPrivateNetworkCondition(pnStatus, vpcv1alpha1.PrivateNetworkCondition{
+			Type:    vpcv1alpha1.PrivateNetworkReady,
+			Status:  status,
+			Reason:  reason,
+			Message: message,
+		})
+	})
+}
+
+// handleAPIError reports the Scaleway API error err in the Ready condition of pn, and returns the matching result
+func (r *PrivateNetworkReconciler) handleAPIError(ctx context.Context, pn *vpcv1alpha1.PrivateNetwork, err error) (ctrl.Result, error) {
+	if patchErr := r.setReady(ctx, pn, corev1.ConditionFalse, reasonForAPIError(err), err.Error()); patchErr != nil {
+		r.Log.Error(patchErr, "could not patch privateNetwork status", "privatenetwork", pn.Name)
+	}
+	return resultForAPIError(err, r.APITransport.RetryAfter())
+}
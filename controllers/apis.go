@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
+	vpc "github.com/scaleway/scaleway-sdk-go/api/vpc/v1"
+	"github.com/scaleway/scaleway-sdk-go/scw"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/scwapi"
+)
+
+// APIs are the Scaleway APIs used to reconcile a PrivateNetwork
+type APIs struct {
+	Client      *scw.Client
+	InstanceAPI *instance.API
+	VpcAPI      *vpc.API
+	VpcGwAPI    *scwapi.VpcGwAPI
+	Servers     *ServerCache
+}
+
+// NewAPIs returns the APIs using scwClient
+func NewAPIs(scwClient *scw.Client, serverCacheTTL time.Duration) *APIs {
+	instanceAPI := instance.NewAPI(scwClient)
+	return &APIs{
+		Client:      scwClient,
+		InstanceAPI: instanceAPI,
+		VpcAPI:      vpc.NewAPI(scwClient),
+		VpcGwAPI:    scwapi.NewVpcGwAPI(scwClient),
+		Servers:     NewServerCache(instanceAPI, serverCacheTTL),
+	}
+}
+
+type cachedAPIs struct {
+	resourceVersion string
+	apis            *APIs
+}
+
+// APIsCache returns the Scaleway APIs of the PrivateNetworks
+// The PrivateNetworks with a credentialsRef get APIs built from their Secret, rebuilt when the Secret changes,
+// the others get the default APIs, which can be swapped at any time
+// The Secrets are read with an uncached reader, so that the Secrets of the cluster are never all cached
+type APIsCache struct {
+	Reader client.Reader
+	// Namespace is the only namespace a credentialsRef can reference, any namespace if empty
+	Namespace      string
+	ServerCacheTTL time.Duration
+	// ClientOptions are the options of all the Scaleway clients, before the credentials
+	ClientOptions []scw.ClientOption
+
+	lock        sync.Mutex
+	defaultAPIs *APIs
+	apis        map[types.NamespacedName]cachedAPIs
+}
+
+// NewAPIsCache returns an APIsCache without default APIs
+func NewAPIsCache(reader client.Reader, namespace string, serverCacheTTL time.Duration, opts ...scw.ClientOption) *APIsCache {
+	return &APIsCache{
+		Reader:         reader,
+		Namespace:      namespace,
+		ServerCacheTTL: serverCacheTTL,
+		ClientOptions:  opts,
+		apis:           make(map[types.NamespacedName]cachedAPIs),
+	}
+}
+
+// NewAPIs returns APIs using the credentials of profile
+func (c *APIsCache) NewAPIs(profile *scw.Profile) (*APIs, error) {
+	opts := append([]scw.ClientOption{}, c.ClientOptions...)
+	opts = append(opts, scw.WithProfile(profile))
+	scwClient, err := scw.NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewAPIs(scwClient, c.ServerCacheTTL), nil
+}
+
+// SetDefault replaces the APIs of the PrivateNetworks without credentialsRef
+func (c *APIsCache) SetDefault(apis *APIs) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.defaultAPIs = apis
+}
+
+// Default returns the APIs of the PrivateNetworks without credentialsRef
+func (c *APIsCache) Default() (*APIs, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.defaultAPIs == nil {
+		return nil, fmt.Errorf("no default scaleway credentials loaded")
+	}
+	return c.defaultAPIs, nil
+}
+
+// ForPrivateNetwork returns the APIs of pn, built from its credentialsRef if set
+func (c *APIsCache) ForPrivateNetwork(ctx context.Context, pn *vpcv1alpha1.PrivateNetwork) (*APIs, error) {
+	if pn.Spec.CredentialsRef == nil {
+		return c.Default()
+	}
+
+	name := types.NamespacedName{
+		Name:      pn.Spec.CredentialsRef.Name,
+		Namespace: pn.Spec.CredentialsRef.Namespace,
+	}
+	if c.Namespace != "" && name.Namespace != c.Namespace {
+		return nil, fmt.Errorf("credentials secret %s is not in the namespace %s", name, c.Namespace)
+	}
+
+	secret := &corev1.Secret{}
+	err := c.Reader.Get(ctx, name, secret)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			c.lock.Lock()
+			delete(c.apis, name)
+			c.lock.Unlock()
+		}
+		return nil, fmt.Errorf("could not get credentials secret %s: %w", name, err)
+	}
+
+	c.lock.Lock()
+	cached, ok := c.apis[name]
+	c.lock.Unlock()
+	if ok && cached.resourceVersion == secret.ResourceVersion {
+		return cached.apis, nil
+	}
+
+	apis, err := c.NewAPIs(scwapi.ProfileFromData(secret.Data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid credentials in secret %s: %w", name, err)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.apis[name] = cachedAPIs{
+		resourceVersion: secret.ResourceVersion,
+		apis:            apis,
+	}
+	return apis, nil
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scaleway/scaleway-sdk-go/scw"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+func credentialsSecret(namespace, name, accessKey string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Data: map[string][]byte{
+			"SCW_ACCESS_KEY": []byte(accessKey),
+			"SCW_SECRET_KEY": []byte("11111111-1111-1111-1111-111111111111"),
+		},
+	}
+}
+
+func privateNetworkWithCredentials(namespace, name string) *vpcv1alpha1.PrivateNetwork {
+	return &vpcv1alpha1.PrivateNetwork{
+		ObjectMeta: metav1.ObjectMeta{Name: "pn"},
+		Spec: vpcv1alpha1.PrivateNetworkSpec{
+			CredentialsRef: &vpcv1alpha1.SecretReference{Namespace: namespace, Name: name},
+		},
+	}
+}
+
+func TestAPIsCacheDefault(t *testing.T) {
+	cache := NewAPIsCache(nil, "", time.Minute)
+	if _, err := cache.ForPrivateNetwork(context.Background(), &vpcv1alpha1.PrivateNetwork{}); err == nil {
+		t.Errorf("expected an error without default credentials")
+	}
+
+	apis, err := cache.NewAPIs(&scw.Profile{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cache.SetDefault(apis)
+	got, err := cache.ForPrivateNetwork(context.Background(), &vpcv1alpha1.PrivateNetwork{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != apis {
+		t.Errorf("expected the default APIs for a privateNetwork without credentialsRef")
+	}
+}
+
+func TestAPIsCacheForPrivateNetwork(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	ctx := context.Background()
+	c := fake.NewFakeClientWithScheme(scheme,
+		credentialsSecret("system", "project", "SCWAAAAAAAAAAAAAAAAA"),
+		credentialsSecret("other", "project", "SCWAAAAAAAAAAAAAAAAA"),
+		credentialsSecret("system", "invalid", "not an access key"),
+	)
+	cache := NewAPIsCache(c, "system", time.Minute)
+	defaultAPIs, err := cache.NewAPIs(&scw.Profile{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cache.SetDefault(defaultAPIs)
+
+	pn := privateNetworkWithCredentials("system", "project")
+	apis, err := cache.ForPrivateNetwork(ctx, pn)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if apis == defaultAPIs {
+		t.Errorf("expected the APIs of the secret, got the default ones")
+	}
+	if accessKey, _ := apis.Client.GetAccessKey(); accessKey != "SCWAAAAAAAAAAAAAAAAA" {
+		t.Errorf("expected the access key of the secret, got %q", accessKey)
+	}
+
+	// cached while the secret doesn't change
+	cached, err := cache.ForPrivateNetwork(ctx, pn)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cached != apis {
+		t.Errorf("expected the cached APIs")
+	}
+
+	// rebuilt when the secret changes
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "system", Name: "project"}, secret); err != nil {
+		t.Fatalf("could not get secret: %s", err)
+	}
+	secret.Data["SCW_ACCESS_KEY"] = []byte("SCWBBBBBBBBBBBBBBBBB")
+	if err := c.Update(ctx, secret); err != nil {
+		t.Fatalf("could not update secret: %s", err)
+	}
+	rebuilt, err := cache.ForPrivateNetwork(ctx, pn)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if accessKey, _ := rebuilt.Client.GetAccessKey(); rebuilt == apis || accessKey != "SCWBBBBBBBBBBBBBBBBB" {
+		t.Errorf("expected the APIs to be rebuilt with the new access key, got %q", accessKey)
+	}
+
+	// dropped when the secret is deleted
+	if err := c.Delete(ctx, secret); err != nil {
+		t.Fatalf("could not delete secret: %s", err)
+	}
+	if _, err := cache.ForPrivateNetwork(ctx, pn); err == nil {
+		t.Errorf("expected an error for a deleted secret")
+	}
+	if _, ok := cache.apis[types.NamespacedName{Namespace: "system", Name: "project"}]; ok {
+		t.Errorf("expected the APIs of a deleted secret to be dropped")
+	}
+
+	for _, tt := range []struct {
+		name string
+		pn   *vpcv1alpha1.PrivateNetwork
+	}{
+		{name: "other namespace", pn: privateNetworkWithCredentials("other", "project")},
+		{name: "missing secret", pn: privateNetworkWithCredentials("system", "missing")},
+		{name: "invalid credentials", pn: privateNetworkWithCredentials("system", "invalid")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := cache.ForPrivateNetwork(ctx, tt.pn); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
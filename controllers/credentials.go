@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
+	"github.com/scaleway/scaleway-sdk-go/scw"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/scwapi"
+)
+
+// DefaultCredentialsReloadPeriod is the default period at which the default credentials are reloaded
+const DefaultCredentialsReloadPeriod = time.Minute
+
+// CredentialsReloader loads the default Scaleway credentials and swaps the default APIs when they change
+// The credentials are read from Dir if set, e.g. a mounted Secret, else from Secret if set, else from the environment
+type CredentialsReloader struct {
+	Reader client.Reader
+	Log    logr.Logger
+	APIs   *APIsCache
+	Dir    string
+	Secret types.NamespacedName
+	Period time.Duration
+
+	lock    sync.Mutex
+	current map[string][]byte
+	err     error
+}
+
+// Start implements manager.Runnable
+func (r *CredentialsReloader) Start(stopCh <-chan struct{}) error {
+	ticker := time.NewTicker(r.Period)
+	defer ticker.Stop()
+
+	for {
+		err := r.Load(context.Background())
+		if err != nil {
+			r.Log.Error(err, "unable to load scaleway credentials")
+		}
+
+		select {
+		case <-stopCh:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, the credentials are needed by all the replicas to be ready
+func (r *CredentialsReloader) NeedLeaderElection() bool {
+	return false
+}
+
+// Load reads the credentials, swaps the default APIs if they changed, and checks they are accepted by the API
+// The previous APIs are kept if the new credentials are invalid
+func (r *CredentialsReloader) Load(ctx context.Context) error {
+	data, err := r.read(ctx)
+	if err != nil {
+		return r.setError(fmt.Errorf("could not read credentials: %w", err))
+	}
+
+	r.lock.Lock()
+	changed := !reflect.DeepEqual(data, r.current)
+	r.lock.Unlock()
+
+	if changed {
+		apis, err := r.APIs.NewAPIs(scwapi.ProfileFromData(data))
+		if err != nil {
+			return r.setError(fmt.Errorf("invalid credentials: %w", err))
+		}
+		r.APIs.SetDefault(apis)
+
+		r.lock.Lock()
+		r.current = data
+		r.lock.Unlock()
+		r.Log.Info("loaded scaleway credentials")
+	}
+
+	apis, err := r.APIs.Default()
+	if err != nil {
+		return r.setError(err)
+	}
+	_, err = apis.InstanceAPI.ListServers(&instance.ListServersRequest{
+		PerPage: scw.Uint32Ptr(1),
+	})
+	if err != nil {
+		if scwapi.Classify(err) == scwapi.ErrorClassPermission {
+			return r.setError(fmt.Errorf("credentials rejected: %w", err))
+		}
+		// the credentials can't be blamed for other errors
+		r.Log.Error(err, "unable to check scaleway credentials")
+	}
+	return r.setError(nil)
+}
+
+// Checker returns a checker failing when the credentials are missing, invalid or rejected
+func (r *CredentialsReloader) Checker() healthz.Checker {
+	return func(_ *http.Request) error {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		return r.err
+	}
+}
+
+func (r *CredentialsReloader) setError(err error) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.err = err
+	return err
+}
+
+func (r *CredentialsReloader) read(ctx context.Context) (map[string][]byte, error) {
+	data := make(map[string][]byte)
+
+	switch {
+	case r.Dir != "":
+		for _, key := range scwapi.ProfileKeys {
+			value, err := ioutil.ReadFile(filepath.Join(r.Dir, key))
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+			data[key] = value
+		}
+	case r.Secret.Name != "":
+		secret := &corev1.Secret{}
+		err := r.Reader.Get(ctx, r.Secret, secret)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range scwapi.ProfileKeys {
+			if value, ok := secret.Data[key]; ok {
+				data[key] = value
+			}
+		}
+	default:
+		for _, key := range scwapi.ProfileKeys {
+			if value, ok := os.LookupEnv(key); ok {
+				data[key] = []byte(value)
+			}
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scaleway/scaleway-sdk-go/scw"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	acceptedSecretKey = "11111111-1111-1111-1111-111111111111"
+	rejectedSecretKey = "22222222-2222-2222-2222-222222222222"
+)
+
+// newTestCredentialsAPIsCache returns an APIsCache whose clients send their requests to a fake instance API,
+// rejecting the rejected secret key
+func newTestCredentialsAPIsCache(t *testing.T) *APIsCache {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("X-Auth-Token") == rejectedSecretKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]string{"message": "authentication is denied"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"servers": []interface{}{}, "total_count": 0})
+	}))
+	t.Cleanup(server.Close)
+
+	return NewAPIsCache(nil, "", time.Minute,
+		scw.WithAPIURL(server.URL),
+		scw.WithDefaultZone(scw.ZoneFrPar1),
+	)
+}
+
+func writeCredentials(t *testing.T, dir, accessKey, secretKey string) {
+	for key, value := range map[string]string{"SCW_ACCESS_KEY": accessKey, "SCW_SECRET_KEY": secretKey} {
+		if err := ioutil.WriteFile(filepath.Join(dir, key), []byte(value+"\n"), 0600); err != nil {
+			t.Fatalf("could not write %s: %s", key, err)
+		}
+	}
+}
+
+func TestCredentialsReloaderDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatalf("could not create directory: %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	r := &CredentialsReloader{
+		Log:  ctrl.Log,
+		APIs: newTestCredentialsAPIsCache(t),
+		Dir:  dir,
+	}
+	checker := r.Checker()
+
+	writeCredentials(t, dir, "SCWAAAAAAAAAAAAAAAAA", acceptedSecretKey)
+	if err := r.Load(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := checker(nil); err != nil {
+		t.Errorf("expected the check to pass, got %s", err)
+	}
+	loaded, err := r.APIs.Default()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// unchanged credentials keep the APIs, and their server cache
+	if err := r.Load(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if apis, _ := r.APIs.Default(); apis != loaded {
+		t.Errorf("expected the APIs to be kept with unchanged credentials")
+	}
+
+	// rotated credentials are swapped
+	writeCredentials(t, dir, "SCWBBBBBBBBBBBBBBBBB", acceptedSecretKey)
+	if err := r.Load(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	rotated, _ := r.APIs.Default()
+	if accessKey, _ := rotated.Client.GetAccessKey(); accessKey != "SCWBBBBBBBBBBBBBBBBB" {
+		t.Errorf("expected the rotated access key, got %q", accessKey)
+	}
+
+	// invalid credentials keep the previous APIs
+	writeCredentials(t, dir, "invalid", acceptedSecretKey)
+	if err := r.Load(context.Background()); err == nil {
+		t.Errorf("expected an error for invalid credentials")
+	}
+	if err := checker(nil); err == nil {
+		t.Errorf("expected the check to fail with invalid credentials")
+	}
+	if apis, _ := r.APIs.Default(); apis != rotated {
+		t.Errorf("expected the previous APIs to be kept with invalid credentials")
+	}
+
+	// rejected credentials fail the check
+	writeCredentials(t, dir, "SCWCCCCCCCCCCCCCCCCC", rejectedSecretKey)
+	if err := r.Load(context.Background()); err == nil {
+		t.Errorf("expected an error for rejected credentials")
+	}
+	if err := checker(nil); err == nil {
+		t.Errorf("expected the check to fail with rejected credentials")
+	}
+
+	// and pass again once fixed
+	writeCredentials(t, dir, "SCWAAAAAAAAAAAAAAAAA", acceptedSecretKey)
+	if err := r.Load(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := checker(nil); err != nil {
+		t.Errorf("expected the check to pass, got %s", err)
+	}
+}
+
+func TestCredentialsReloaderSecret(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	secret := credentialsSecret("system", "credentials", "SCWAAAAAAAAAAAAAAAAA")
+	c := fake.NewFakeClientWithScheme(scheme, secret)
+	r := &CredentialsReloader{
+		Reader: c,
+		Log:    ctrl.Log,
+		APIs:   newTestCredentialsAPIsCache(t),
+		Secret: types.NamespacedName{Namespace: "system", Name: "credentials"},
+	}
+
+	if err := r.Load(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	apis, err := r.APIs.Default()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if accessKey, _ := apis.Client.GetAccessKey(); accessKey != "SCWAAAAAAAAAAAAAAAAA" {
+		t.Errorf("expected the access key of the secret, got %q", accessKey)
+	}
+
+	if err := c.Delete(context.Background(), secret); err != nil {
+		t.Fatalf("could not delete secret: %s", err)
+	}
+	if err := r.Load(context.Background()); err == nil {
+		t.Errorf("expected an error for a missing secret")
+	}
+	if err := r.Checker()(nil); err == nil {
+		t.Errorf("expected the check to fail for a missing secret")
+	}
+}
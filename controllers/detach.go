@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+)
+
+const (
+	// podNodeNameField is the field selector of the pods of a node
+	podNodeNameField = "spec.nodeName"
+	// mirrorPodAnnotation is set on the static pods, which are not evicted by a drain
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+)
+
+// DetachRequeueDuration is the requeue duration of a NetworkInterface waiting for its node to be drained
+var DetachRequeueDuration time.Duration = time.Second * 10
+
+// activePodsOnNode returns the pods of the node which are not terminated
+// They are read from the API server, so that the pods of the whole cluster are not cached for the few detaches waiting
+func (r *NetworkInterfaceReconciler) activePodsOnNode(ctx context.Context, nodeName string) ([]corev1.Pod, error) {
+	podsList := &corev1.PodList{}
+	err := r.Reader.List(ctx, podsList, client.MatchingFields{podNodeNameField: nodeName})
+	if err != nil {
+		return nil, err
+	}
+	pods := []corev1.Pod{}
+	for _, pod := range podsList.Items {
+		if pod.Spec.NodeName == nodeName && pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+// isEvictable returns whether the pod is removed from the node by a drain, DaemonSet and static pods are not
+func isEvictable(pod *corev1.Pod) bool {
+	if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+		return false
+	}
+	if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "DaemonSet" {
+		return false
+	}
+	return true
+}
+
+// drainStatus returns what the detach is waiting for before the node is cordoned and drained, or nil if it is
+func drainStatus(node *corev1.Node, pods []corev1.Pod) *vpcv1alpha1.NetworkInterfaceDetachStatus {
+	if !node.Spec.Unschedulable {
+		return &vpcv1alpha1.NetworkInterfaceDetachStatus{
+			Phase:   vpcv1alpha1.DetachPhaseWaitingForDrain,
+			Message: fmt.Sprintf("node %s is not cordoned", node.Name),
+		}
+	}
+	left := 0
+	for i := range pods {
+		if isEvictable(&pods[i]) {
+			left++
+		}
+	}
+	if left != 0 {
+		return &vpcv1alpha1.NetworkInterfaceDetachStatus{
+			Phase:   vpcv1alpha1.DetachPhaseWaitingForDrain,
+			Message: fmt.Sprintf("%d pods left on node %s", left, node.Name),
+		}
+	}
+	return nil
+}
+
+// selectedPodsStatus returns what the detach is waiting for before the selected pods leave the node, or nil if they did
+func selectedPodsStatus(podSelector *metav1.LabelSelector, node *corev1.Node, pods []corev1.Pod) (*vpcv1alpha1.NetworkInterfaceDetachStatus, error) {
+	selector, err := metav1.LabelSelectorAsSelector(podSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pod selector: %w", err)
+	}
+	left := 0
+	for _, pod := range pods {
+		if selector.Matches(labels.Set(pod.Labels)) {
+			left++
+		}
+	}
+	if left != 0 {
+		return &vpcv1alpha1.NetworkInterfaceDetachStatus{
+			Phase:   vpcv1alpha1.DetachPhaseWaitingForPods,
+			Message: fmt.Sprintf("%d selected pods left on node %s", left, node.Name),
+		}, nil
+	}
+	return nil, nil
+}
+
+// removalStatus returns what the detach is waiting for before the node is drained or the selected pods left it, or nil if
+// either of them happened
+func removalStatus(detach *vpcv1alpha1.PrivateNetworkDetach, node *corev1.Node, pods []corev1.Pod) (*vpcv1alpha1.NetworkInterfaceDetachStatus, error) {
+	var waiting *vpcv1alpha1.NetworkInterfaceDetachStatus
+	if detach.WaitForDrain {
+		waiting = drainStatus(node, pods)
+		if waiting == nil {
+			return nil, nil
+		}
+	}
+	if detach.PodSelector != nil {
+		podsWaiting, err := selectedPodsStatus(detach.PodSelector, node, pods)
+		if err != nil || podsWaiting == nil {
+			return nil, err
+		}
+		if waiting == nil {
+			waiting = podsWaiting
+		}
+	}
+	return waiting, nil
+}
+
+// waitStatus returns what the detach is waiting for before the pods are gone, or nil if it can start the grace period
+// node is nil if the node is deleted, in which case there are no pods to wait for
+// A node being removed waits to be drained or for the selected pods to leave it, any other node, e.g. when the
+// PrivateNetwork is deleted, only waits for the selected pods to leave it, since it is not going to be drained
+func waitStatus(detach *vpcv1alpha1.PrivateNetworkDetach, node *corev1.Node, pods []corev1.Pod) (*vpcv1alpha1.NetworkInterfaceDetachStatus, error) {
+	switch {
+	case node == nil:
+		return nil, nil
+	case !node.ObjectMeta.GetDeletionTimestamp().IsZero():
+		return removalStatus(detach, node, pods)
+	case detach.PodSelector != nil:
+		return selectedPodsStatus(detach.PodSelector, node, pods)
+	}
+	return nil, nil
+}
+
+// detachStatus returns what the detach of the NetworkInterface from the node is waiting for, or nil if it can be detached,
+// and when to check it again
+// node is nil if the node is deleted, in which case only the grace period applies
+// With both WaitForDrain and PodSelector, the grace period starts as soon as the node is drained or the selected pods left it
+func (r *NetworkInterfaceReconciler) detachStatus(ctx context.Context, nic *vpcv1alpha1.NetworkInterface, detach *vpcv1alpha1.PrivateNetworkDetach, node *corev1.Node) (*vpcv1alpha1.NetworkInterfaceDetachStatus, time.Duration, error) {
+	if node != nil && (detach.WaitForDrain || detach.PodSelector != nil) {
+		pods, err := r.activePodsOnNode(ctx, node.Name)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		waiting, err := waitStatus(detach, node, pods)
+		if err != nil {
+			return nil, 0, err
+		}
+		if waiting != nil {
+			return waiting, DetachRequeueDuration, nil
+		}
+	}
+
+	if detach.GracePeriod == nil || detach.GracePeriod.Duration <= 0 {
+		return nil, 0, nil
+	}
+	// the status is stored with a second precision
+	drainedAt := metav1.NewTime(time.Now().Truncate(time.Second))
+	if nic.Status.Detach != nil && nic.Status.Detach.DrainedAt != nil {
+		drainedAt = *nic.Status.Detach.DrainedAt
+	}
+	detachAt := drainedAt.Add(detach.GracePeriod.Duration)
+	remaining := time.Until(detachAt)
+	if remaining <= 0 {
+		return nil, 0, nil
+	}
+	return &vpcv1alpha1.NetworkInterfaceDetachStatus{
+		Phase:     vpcv1alpha1.DetachPhaseGracePeriod,
+		Message:   fmt.Sprintf("detaching at %s", detachAt.Format(time.RFC3339)),
+		DrainedAt: &drainedAt,
+	}, remaining, nil
+}
+
+// reconcileDetach holds the detach of the deleted NetworkInterface until the pods are gone, by keeping the detach finalizer
+// node is nil if the node is deleted, in which case only the grace period applies
+func (r *NetworkInterfaceReconciler) reconcileDetach(ctx context.Context, log logr.Logger, nic *vpcv1alpha1.NetworkInterface, pn *vpcv1alpha1.PrivateNetwork, node *corev1.Node) (ctrl.Result, error) {
+	var status *vpcv1alpha1.NetworkInterfaceDetachStatus
+	var requeueAfter time.Duration
+	if pn.Spec.Detach != nil {
+		var err error
+		status, requeueAfter, err = r.detachStatus(ctx, nic, pn.Spec.Detach, node)
+		if err != nil {
+			log.Error(err, "could not check if the node is drained")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if status == nil {
+		patch := client.MergeFrom(nic.DeepCopy())
+		controllerutil.RemoveFinalizer(nic, constants.DetachFinalizerName)
+		err := r.Client.Patch(ctx, nic, patch)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("failed to patch networkInterface %s", nic.Name))
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !equality.Semantic.DeepEqual(nic.Status.Detach, status) {
+		phaseChanged := nic.Status.Detach == nil || nic.Status.Detach.Phase != status.Phase
+		patch := client.MergeFrom(nic.DeepCopy())
+		nic.Status.Detach = status
+		err := r.Client.Status().Patch(ctx, nic, patch)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("failed to patch networkInterface %s status", nic.Name))
+			return ctrl.Result{}, err
+		}
+		if phaseChanged {
+			r.Recorder.Event(nic, corev1.EventTypeNormal, constants.ReasonDetachWaiting, fmt.Sprintf("Waiting to detach: %s", status.Message))
+		}
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
@@ -0,0 +1,260 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+)
+
+func TestRemovalStatus(t *testing.T) {
+	cordoned := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node"},
+		Spec:       corev1.NodeSpec{Unschedulable: true},
+	}
+	schedulable := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node"},
+	}
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db-client"}}
+	selected := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "client", Labels: map[string]string{"app": "db-client"}}}
+	other := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other"}}
+	daemon := corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "daemon",
+		OwnerReferences: []metav1.OwnerReference{{
+			Kind:       "DaemonSet",
+			Name:       "daemon",
+			Controller: func() *bool { b := true; return &b }(),
+		}},
+	}}
+
+	tests := []struct {
+		name     string
+		detach   *vpcv1alpha1.PrivateNetworkDetach
+		node     *corev1.Node
+		pods     []corev1.Pod
+		expected vpcv1alpha1.DetachPhase
+	}{
+		{
+			name:     "not cordoned",
+			detach:   &vpcv1alpha1.PrivateNetworkDetach{WaitForDrain: true},
+			node:     schedulable,
+			expected: vpcv1alpha1.DetachPhaseWaitingForDrain,
+		},
+		{
+			name:     "not drained",
+			detach:   &vpcv1alpha1.PrivateNetworkDetach{WaitForDrain: true},
+			node:     cordoned,
+			pods:     []corev1.Pod{other, daemon},
+			expected: vpcv1alpha1.DetachPhaseWaitingForDrain,
+		},
+		{
+			name:   "drained",
+			detach: &vpcv1alpha1.PrivateNetworkDetach{WaitForDrain: true},
+			node:   cordoned,
+			pods:   []corev1.Pod{daemon},
+		},
+		{
+			name:     "selected pods left",
+			detach:   &vpcv1alpha1.PrivateNetworkDetach{PodSelector: selector},
+			node:     schedulable,
+			pods:     []corev1.Pod{selected, other},
+			expected: vpcv1alpha1.DetachPhaseWaitingForPods,
+		},
+		{
+			name:   "selected pods gone",
+			detach: &vpcv1alpha1.PrivateNetworkDetach{PodSelector: selector},
+			node:   schedulable,
+			pods:   []corev1.Pod{other},
+		},
+		{
+			name:   "drained with selected pods left",
+			detach: &vpcv1alpha1.PrivateNetworkDetach{WaitForDrain: true, PodSelector: selector},
+			node:   cordoned,
+			pods:   []corev1.Pod{daemon},
+		},
+		{
+			name:   "selected pods gone without drain",
+			detach: &vpcv1alpha1.PrivateNetworkDetach{WaitForDrain: true, PodSelector: selector},
+			node:   schedulable,
+			pods:   []corev1.Pod{other},
+		},
+		{
+			name:     "neither",
+			detach:   &vpcv1alpha1.PrivateNetworkDetach{WaitForDrain: true, PodSelector: selector},
+			node:     schedulable,
+			pods:     []corev1.Pod{selected},
+			expected: vpcv1alpha1.DetachPhaseWaitingForDrain,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := removalStatus(tt.detach, tt.node, tt.pods)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			var phase vpcv1alpha1.DetachPhase
+			if status != nil {
+				phase = status.Phase
+			}
+			if phase != tt.expected {
+				t.Errorf("expected phase %q, got %q", tt.expected, phase)
+			}
+		})
+	}
+}
+
+func TestReconcileDetach(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = vpcv1alpha1.AddToScheme(scheme)
+
+	deleted := metav1.NewTime(time.Now())
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db-client"}}
+	privateNetwork := func(detach *vpcv1alpha1.PrivateNetworkDetach, deleting bool) *vpcv1alpha1.PrivateNetwork {
+		pn := &vpcv1alpha1.PrivateNetwork{
+			ObjectMeta: metav1.ObjectMeta{Name: "pn"},
+			Spec:       vpcv1alpha1.PrivateNetworkSpec{ID: "pn-id", Detach: detach},
+		}
+		if deleting {
+			pn.DeletionTimestamp = &deleted
+			pn.Finalizers = []string{constants.FinalizerName}
+		}
+		return pn
+	}
+	node := func(removing bool) *corev1.Node {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+		if removing {
+			node.DeletionTimestamp = &deleted
+			node.Finalizers = []string{"example.com/hold"}
+		}
+		return node
+	}
+	pod := func(name, nodeName string, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+			Spec:       corev1.PodSpec{NodeName: nodeName},
+		}
+	}
+	drainedAt := func(ago time.Duration) *vpcv1alpha1.NetworkInterfaceDetachStatus {
+		at := metav1.NewTime(time.Now().Add(-ago).Truncate(time.Second))
+		return &vpcv1alpha1.NetworkInterfaceDetachStatus{Phase: vpcv1alpha1.DetachPhaseGracePeriod, DrainedAt: &at}
+	}
+
+	tests := []struct {
+		name     string
+		objs     []runtime.Object
+		status   *vpcv1alpha1.NetworkInterfaceDetachStatus
+		expected vpcv1alpha1.DetachPhase
+	}{
+		{
+			name: "node deleted",
+			objs: []runtime.Object{
+				privateNetwork(&vpcv1alpha1.PrivateNetworkDetach{WaitForDrain: true, PodSelector: selector, GracePeriod: &metav1.Duration{Duration: time.Minute}}, false),
+			},
+			expected: vpcv1alpha1.DetachPhaseGracePeriod,
+		},
+		{
+			name: "node deleted after the grace period",
+			objs: []runtime.Object{
+				privateNetwork(&vpcv1alpha1.PrivateNetworkDetach{WaitForDrain: true, GracePeriod: &metav1.Duration{Duration: time.Minute}}, false),
+			},
+			status: drainedAt(time.Minute * 2),
+		},
+		{
+			name: "node being removed not drained",
+			objs: []runtime.Object{
+				privateNetwork(&vpcv1alpha1.PrivateNetworkDetach{WaitForDrain: true}, false),
+				node(true),
+				pod("other", "node", nil),
+			},
+			expected: vpcv1alpha1.DetachPhaseWaitingForDrain,
+		},
+		{
+			name: "privateNetwork deleted with selected pods left",
+			objs: []runtime.Object{
+				privateNetwork(&vpcv1alpha1.PrivateNetworkDetach{WaitForDrain: true, PodSelector: selector}, true),
+				node(false),
+				pod("client", "node", selector.MatchLabels),
+			},
+			expected: vpcv1alpha1.DetachPhaseWaitingForPods,
+		},
+		{
+			name: "privateNetwork deleted with selected pods on other nodes",
+			objs: []runtime.Object{
+				privateNetwork(&vpcv1alpha1.PrivateNetworkDetach{PodSelector: selector, GracePeriod: &metav1.Duration{Duration: time.Minute}}, true),
+				node(false),
+				pod("client", "other", selector.MatchLabels),
+			},
+			expected: vpcv1alpha1.DetachPhaseGracePeriod,
+		},
+		{
+			name: "privateNetwork deleted without pod selector",
+			objs: []runtime.Object{
+				privateNetwork(&vpcv1alpha1.PrivateNetworkDetach{WaitForDrain: true}, true),
+				node(false),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nic := &vpcv1alpha1.NetworkInterface{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "pn-node",
+					DeletionTimestamp: &deleted,
+					Finalizers:        []string{constants.DetachFinalizerName, constants.IPFinalizerName},
+					OwnerReferences:   []metav1.OwnerReference{{Name: "pn"}},
+				},
+				Spec:   vpcv1alpha1.NetworkInterfaceSpec{ID: "nic", NodeName: "node"},
+				Status: vpcv1alpha1.NetworkInterfaceStatus{Detach: tt.status},
+			}
+			c := fake.NewFakeClientWithScheme(scheme, append(tt.objs, nic)...)
+			r := &NetworkInterfaceReconciler{
+				Client:   c,
+				Reader:   c,
+				Log:      ctrl.Log.WithName("test"),
+				Scheme:   scheme,
+				Recorder: record.NewFakeRecorder(10),
+			}
+
+			result, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: nic.Name}})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			err = c.Get(context.Background(), types.NamespacedName{Name: nic.Name}, nic)
+			if err != nil {
+				t.Fatalf("could not get networkInterface: %s", err)
+			}
+			holding := controllerutil.ContainsFinalizer(nic, constants.DetachFinalizerName)
+			if tt.expected == "" {
+				if holding {
+					t.Errorf("expected the detach not to be held, got status %+v", nic.Status.Detach)
+				}
+				return
+			}
+			if !holding {
+				t.Fatalf("expected the detach to be held by %s", tt.expected)
+			}
+			if nic.Status.Detach == nil || nic.Status.Detach.Phase != tt.expected {
+				t.Errorf("expected phase %s, got %+v", tt.expected, nic.Status.Detach)
+			}
+			if result.RequeueAfter <= 0 {
+				t.Errorf("expected a requeue")
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/scaleway/scaleway-sdk-go/scw"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/scwapi"
+)
+
+// dhcpGatewayNetwork returns the gateway network of the private network with a DHCP server, where the addresses are reserved
+// The Public Gateway of the PrivateNetwork is used if set
+func dhcpGatewayNetwork(apis *APIs, pn *vpcv1alpha1.PrivateNetwork) (*scwapi.GatewayNetwork, error) {
+	if pn.Spec.Gateway != nil {
+		gatewayNetwork, err := getGatewayNetwork(apis, pn, pn.Spec.Gateway.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !gatewayNetwork.EnableDHCP {
+			return nil, fmt.Errorf("public gateway %s has no DHCP enabled on private network %s", pn.Spec.Gateway.ID, pn.PrivateNetworkID())
+		}
+		return gatewayNetwork, nil
+	}
+
+	gatewayNetworks, err := apis.VpcGwAPI.ListGatewayNetworks(scw.Zone(pn.Spec.Zone), pn.PrivateNetworkID())
+	if err != nil {
+		return nil, err
+	}
+	for _, gatewayNetwork := range gatewayNetworks {
+		if gatewayNetwork.EnableDHCP {
+			return gatewayNetwork, nil
+		}
+	}
+	return nil, fmt.Errorf("private network %s is not attached to a Public Gateway with DHCP enabled", pn.PrivateNetworkID())
+}
+
+// reserveAddress reserves the IP address for the mac address in the DHCP server of the gateway network
+// An existing reservation of the mac address is kept if it has the same IP address, and deleted otherwise
+func reserveAddress(apis *APIs, pn *vpcv1alpha1.PrivateNetwork, gatewayNetwork *scwapi.GatewayNetwork, macAddress, ipAddress string) (*scwapi.DHCPEntry, error) {
+	zone := scw.Zone(pn.Spec.Zone)
+	entries, err := apis.VpcGwAPI.ListDHCPEntries(zone, gatewayNetwork.ID, macAddress)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IPAddress == ipAddress {
+			return entry, nil
+		}
+		err := apis.VpcGwAPI.DeleteDHCPEntry(zone, entry.ID)
+		if err != nil && scwapi.Classify(err) != scwapi.ErrorClassNotFound {
+			return nil, err
+		}
+	}
+	return apis.VpcGwAPI.CreateDHCPEntry(zone, gatewayNetwork.ID, macAddress, ipAddress)
+}
+
+// releaseReservation deletes the reservation of the address of the NetworkInterface, if any, and clears its DHCPEntryID
+// A reservation already deleted, e.g. by hand, is considered released
+func releaseReservation(apis *APIs, pn *vpcv1alpha1.PrivateNetwork, nic *vpcv1alpha1.NetworkInterface) error {
+	if nic.Status.DHCPEntryID == "" {
+		return nil
+	}
+	err := apis.VpcGwAPI.DeleteDHCPEntry(scw.Zone(pn.Spec.Zone), nic.Status.DHCPEntryID)
+	if err != nil && scwapi.Classify(err) != scwapi.ErrorClassNotFound {
+		return err
+	}
+	nic.Status.DHCPEntryID = ""
+	return nil
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/scaleway/scaleway-sdk-go/scw"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/scwapi"
+)
+
+const dhcpEntriesPath = "/vpc-gw/v1/zones/fr-par-1/dhcp-entries"
+
+// fakeDHCPEntries is a fake of the DHCP entries endpoints of the Public Gateway API
+type fakeDHCPEntries struct {
+	lock    sync.Mutex
+	entries map[string]scwapi.DHCPEntry
+	deleted []string
+	created int
+}
+
+func (f *fakeDHCPEntries) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == dhcpEntriesPath:
+		entries := []scwapi.DHCPEntry{}
+		for _, entry := range f.entries {
+			if entry.GatewayNetworkID == r.URL.Query().Get("gateway_network_id") && entry.MacAddress == r.URL.Query().Get("mac_address") {
+				entries = append(entries, entry)
+			}
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"dhcp_entries": entries})
+	case r.Method == http.MethodPost && r.URL.Path == dhcpEntriesPath:
+		entry := scwapi.DHCPEntry{}
+		if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.created++
+		entry.ID = fmt.Sprintf("created-%d", f.created)
+		entry.Type = scwapi.DHCPEntryTypeReservation
+		f.entries[entry.ID] = entry
+		_ = json.NewEncoder(w).Encode(entry)
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, dhcpEntriesPath+"/"):
+		id := strings.TrimPrefix(r.URL.Path, dhcpEntriesPath+"/")
+		if _, ok := f.entries[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]string{"message": "dhcp entry not found"})
+			return
+		}
+		delete(f.entries, id)
+		f.deleted = append(f.deleted, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// ips returns the reserved IPs, sorted
+func (f *fakeDHCPEntries) ips() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	ips := []string{}
+	for _, entry := range f.entries {
+		ips = append(ips, entry.IPAddress)
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+// newTestAPIs returns the APIs sending the Public Gateway requests to the fake
+func newTestAPIs(t *testing.T, fake *fakeDHCPEntries) *APIs {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client, err := scw.NewClient(
+		scw.WithoutAuth(),
+		scw.WithAPIURL(server.URL),
+		scw.WithDefaultZone(scw.ZoneFrPar1),
+	)
+	if err != nil {
+		t.Fatalf("could not create client: %s", err)
+	}
+	return &APIs{
+		Client:   client,
+		VpcGwAPI: scwapi.NewVpcGwAPI(client),
+	}
+}
+
+func reservation(id, macAddress, ipAddress string) scwapi.DHCPEntry {
+	return scwapi.DHCPEntry{
+		ID:               id,
+		GatewayNetworkID: "gwn",
+		MacAddress:       macAddress,
+		IPAddress:        ipAddress,
+		Type:             scwapi.DHCPEntryTypeReservation,
+	}
+}
+
+func TestReserveAddress(t *testing.T) {
+	tests := []struct {
+		name        string
+		entries     []scwapi.DHCPEntry
+		expectedID  string
+		expectedIPs []string
+	}{
+		{
+			name:        "new reservation",
+			expectedID:  "created-1",
+			expectedIPs: []string{"192.168.1.10"},
+		},
+		{
+			name:        "same address kept",
+			entries:     []scwapi.DHCPEntry{reservation("existing", "02:00:00:00:00:01", "192.168.1.10")},
+			expectedID:  "existing",
+			expectedIPs: []string{"192.168.1.10"},
+		},
+		{
+			name:        "other address replaced",
+			entries:     []scwapi.DHCPEntry{reservation("existing", "02:00:00:00:00:01", "192.168.1.20")},
+			expectedID:  "created-1",
+			expectedIPs: []string{"192.168.1.10"},
+		},
+		{
+			name:        "other mac address untouched",
+			entries:     []scwapi.DHCPEntry{reservation("other", "02:00:00:00:00:02", "192.168.1.20")},
+			expectedID:  "created-1",
+			expectedIPs: []string{"192.168.1.10", "192.168.1.20"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeDHCPEntries{entries: map[string]scwapi.DHCPEntry{}}
+			for _, entry := range tt.entries {
+				fake.entries[entry.ID] = entry
+			}
+			apis := newTestAPIs(t, fake)
+			pn := staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeReserved, "192.168.1.0/24")
+
+			entry, err := reserveAddress(apis, &pn, &scwapi.GatewayNetwork{ID: "gwn"}, "02:00:00:00:00:01", "192.168.1.10")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if entry.ID != tt.expectedID || entry.IPAddress != "192.168.1.10" {
+				t.Errorf("expected reservation %s of 192.168.1.10, got %s of %s", tt.expectedID, entry.ID, entry.IPAddress)
+			}
+			if ips := fake.ips(); !reflect.DeepEqual(ips, tt.expectedIPs) {
+				t.Errorf("expected reserved IPs %v, got %v", tt.expectedIPs, ips)
+			}
+		})
+	}
+}
+
+func TestReleaseReservation(t *testing.T) {
+	tests := []struct {
+		name            string
+		dhcpEntryID     string
+		entries         []scwapi.DHCPEntry
+		expectedDeleted []string
+	}{
+		{
+			name:            "reserved",
+			dhcpEntryID:     "entry",
+			entries:         []scwapi.DHCPEntry{reservation("entry", "02:00:00:00:00:01", "192.168.1.10")},
+			expectedDeleted: []string{"entry"},
+		},
+		{
+			name:        "already deleted",
+			dhcpEntryID: "entry",
+		},
+		{
+			name:    "not reserved",
+			entries: []scwapi.DHCPEntry{reservation("other", "02:00:00:00:00:02", "192.168.1.20")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeDHCPEntries{entries: map[string]scwapi.DHCPEntry{}}
+			for _, entry := range tt.entries {
+				fake.entries[entry.ID] = entry
+			}
+			apis := newTestAPIs(t, fake)
+			pn := staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeReserved, "192.168.1.0/24")
+			nic := &vpcv1alpha1.NetworkInterface{
+				ObjectMeta: metav1.ObjectMeta{Name: "pn-node"},
+				Status: vpcv1alpha1.NetworkInterfaceStatus{
+					Address:     "192.168.1.10/24",
+					DHCPEntryID: tt.dhcpEntryID,
+				},
+			}
+
+			err := releaseReservation(apis, &pn, nic)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if nic.Status.DHCPEntryID != "" {
+				t.Errorf("expected DHCPEntryID to be cleared, got %s", nic.Status.DHCPEntryID)
+			}
+			if !reflect.DeepEqual(fake.deleted, tt.expectedDeleted) {
+				t.Errorf("expected deleted reservations %v, got %v", tt.expectedDeleted, fake.deleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/go-logr/logr"
+	goipam "github.com/metal-stack/go-ipam"
+	"github.com/scaleway/scaleway-sdk-go/scw"
+	corev1 "k8s.io/api/core/v1"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/scwapi"
+)
+
+// getGatewayNetwork returns the connection of the Public Gateway to the private network of pn
+// A Public Gateway not attached to the private network is reported as a resource not found
+func getGatewayNetwork(apis *APIs, pn *vpcv1alpha1.PrivateNetwork, gatewayID string) (*scwapi.GatewayNetwork, error) {
+	gatewayNetworks, err := apis.VpcGwAPI.ListGatewayNetworks(scw.Zone(pn.Spec.Zone), pn.PrivateNetworkID())
+	if err != nil {
+		return nil, err
+	}
+	for _, gatewayNetwork := range gatewayNetworks {
+		if gatewayNetwork.GatewayID == gatewayID {
+			return gatewayNetwork, nil
+		}
+	}
+	return nil, &scw.ResourceNotFoundError{
+		Resource:   "gateway_network",
+		ResourceID: gatewayID,
+	}
+}
+
+// getGatewayAddress returns the address of the Public Gateway of pn in the private network, or an empty string without gateway
+// The gateway networks with DHCP enabled have no static address, the gateway having the address of the DHCP server
+func getGatewayAddress(apis *APIs, pn *vpcv1alpha1.PrivateNetwork) (string, error) {
+	if pn.Spec.Gateway == nil {
+		return "", nil
+	}
+	gatewayNetwork, err := getGatewayNetwork(apis, pn, pn.Spec.Gateway.ID)
+	if err != nil {
+		return "", err
+	}
+	address := gatewayNetwork.Address
+	if address == "" && gatewayNetwork.DHCP != nil {
+		address = gatewayNetwork.DHCP.Address
+	}
+	return strings.Split(address, "/")[0], nil
+}
+
+// reconcileGateway reports the address of the Public Gateway of pn in its status, and acquires it in the IPAM
+// so that it is never allocated to a NetworkInterface
+func (r *PrivateNetworkReconciler) reconcileGateway(ctx context.Context, log logr.Logger, pn *vpcv1alpha1.PrivateNetwork, apis *APIs) error {
+	gatewayAddress, err := getGatewayAddress(apis, pn)
+	if err != nil {
+		log.Error(err, "error getting public gateway from api")
+		return err
+	}
+	if pn.Spec.Gateway != nil && gatewayAddress == "" {
+		r.Recorder.Event(pn, corev1.EventTypeWarning, constants.ReasonGatewayAddressNotFound,
+			fmt.Sprintf("Public gateway %s has no address in private network %s", pn.Spec.Gateway.ID, pn.PrivateNetworkID()))
+	}
+
+	if pn.Status.GatewayAddress != "" && pn.Status.GatewayAddress != gatewayAddress {
+		err := r.releaseGatewayAddress(pn)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("could not release previous gateway address %s", pn.Status.GatewayAddress))
+			return err
+		}
+	}
+	if gatewayAddress != "" {
+		err := r.acquireGatewayAddress(pn, gatewayAddress)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("could not acquire gateway address %s", gatewayAddress))
+			return err
+		}
+	}
+
+	err = r.updateStatus(ctx, pn, func(status *vpcv1alpha1.PrivateNetworkStatus) {
+		status.GatewayAddress = gatewayAddress
+	})
+	if err != nil {
+		log.Error(err, "could not patch privateNetwork status")
+		return err
+	}
+	return nil
+}
+
+// gatewayRange returns the IPAM range of pn containing the address, or an empty string if none does
+func gatewayRange(pn *vpcv1alpha1.PrivateNetwork, address string) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return ""
+	}
+	for _, cidr := range pn.IPAMRanges() {
+		_, rangeNet, err := net.ParseCIDR(cidr)
+		if err == nil && rangeNet.Contains(ip) {
+			return cidr
+		}
+	}
+	return ""
+}
+
+// acquireGatewayAddress acquires the address of the Public Gateway in the IPAM range of pn containing it, if any
+func (r *PrivateNetworkReconciler) acquireGatewayAddress(pn *vpcv1alpha1.PrivateNetwork, address string) error {
+	cidr := gatewayRange(pn, address)
+	if cidr == "" {
+		return nil
+	}
+	prefix, err := r.IPAM.NewPrefix(cidr)
+	if err != nil {
+		return fmt.Errorf("could not create prefix %s: %w", cidr, err)
+	}
+	// go-ipam returns no IP and no error when the address is already acquired
+	_, err = r.IPAM.AcquireSpecificIP(prefix.Cidr, address)
+	if err != nil {
+		return fmt.Errorf("could not acquire address %s in %s: %w", address, prefix.Cidr, err)
+	}
+	return nil
+}
+
+// releaseGatewayAddress releases the address of the Public Gateway reported in the status of pn
+func (r *PrivateNetworkReconciler) releaseGatewayAddress(pn *vpcv1alpha1.PrivateNetwork) error {
+	cidr := gatewayRange(pn, pn.Status.GatewayAddress)
+	if cidr == "" {
+		return nil
+	}
+	err := r.IPAM.ReleaseIPFromPrefix(cidr, pn.Status.GatewayAddress)
+	if err != nil && !errors.As(err, &goipam.NotFoundError{}) {
+		return err
+	}
+	return nil
+}
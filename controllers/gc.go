@@ -0,0 +1,377 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	goipam "github.com/metal-stack/go-ipam"
+	"github.com/prometheus/client_golang/prometheus"
+	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
+	"github.com/scaleway/scaleway-sdk-go/scw"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/ipam"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/scwapi"
+)
+
+// DefaultGCPeriod is the default period of the garbage collection
+const DefaultGCPeriod = time.Minute * 10
+
+const (
+	orphanKindPrivateNIC = "private_nic"
+	orphanKindIP         = "ip"
+	orphanKindPrefix     = "prefix"
+)
+
+var (
+	gcOrphans = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "gc",
+		Name:      "orphaned_resources",
+		Help:      "Number of orphaned resources found by the last garbage collection per kind",
+	}, []string{"kind"})
+	gcCollected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "gc",
+		Name:      "collected_resources_total",
+		Help:      "Number of orphaned resources deleted by the garbage collection per kind",
+	}, []string{"kind"})
+	gcErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "gc",
+		Name:      "errors_total",
+		Help:      "Number of garbage collections which failed",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(gcOrphans, gcCollected, gcErrors)
+}
+
+// orphan is a resource not owned by any object of the cluster
+type orphan struct {
+	kind string
+	key  string
+	// collect deletes the resource
+	collect func() error
+	// objects are the objects the events are recorded on
+	objects []runtime.Object
+	message string
+}
+
+// GarbageCollector periodically looks for the private NICs created by the controller without NetworkInterface,
+// and the IPAM addresses and prefixes without NetworkInterface or PrivateNetwork
+// A resource is only deleted when it is still orphaned at the next collection, so that the resources being created are left alone,
+// and never in dry run mode, where the orphans are only reported
+type GarbageCollector struct {
+	Client   client.Client
+	Log      logr.Logger
+	APIs     *APIsCache
+	IPAM     goipam.Ipamer
+	Storage  goipam.Storage
+	Recorder record.EventRecorder
+	Period   time.Duration
+	DryRun   bool
+
+	// previous are the keys of the orphans found by the previous collection
+	previous map[string]bool
+}
+
+// Start implements manager.Runnable
+func (g *GarbageCollector) Start(stopCh <-chan struct{}) error {
+	ticker := time.NewTicker(g.Period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return nil
+		case <-ticker.C:
+		}
+
+		err := g.Collect(context.Background())
+		if err != nil {
+			gcErrors.Inc()
+			g.Log.Error(err, "garbage collection failed")
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, only the leader deletes the orphans
+func (g *GarbageCollector) NeedLeaderElection() bool {
+	return true
+}
+
+// Collect finds the orphans, and deletes the ones already found by the previous collection
+func (g *GarbageCollector) Collect(ctx context.Context) error {
+	pnsList := &vpcv1alpha1.PrivateNetworkList{}
+	err := g.Client.List(ctx, pnsList)
+	if err != nil {
+		return fmt.Errorf("could not list privateNetworks: %w", err)
+	}
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err = g.Client.List(ctx, nicsList)
+	if err != nil {
+		return fmt.Errorf("could not list networkInterfaces: %w", err)
+	}
+	nodesList := &corev1.NodeList{}
+	err = g.Client.List(ctx, nodesList)
+	if err != nil {
+		return fmt.Errorf("could not list nodes: %w", err)
+	}
+
+	orphans := g.orphanedPrivateNICs(ctx, pnsList.Items, nicsList.Items, nodesList.Items)
+	ipamOrphans, err := g.orphanedIPAMEntries(pnsList.Items, nicsList.Items)
+	if err != nil {
+		return err
+	}
+	orphans = append(orphans, ipamOrphans...)
+
+	counts := map[string]int{
+		orphanKindPrivateNIC: 0,
+		orphanKindIP:         0,
+		orphanKindPrefix:     0,
+	}
+	current := make(map[string]bool)
+	for _, o := range orphans {
+		counts[o.kind]++
+		key := o.kind + "/" + o.key
+		current[key] = true
+
+		if g.DryRun || !g.previous[key] {
+			g.Log.Info(fmt.Sprintf("found orphaned %s", o.message), "dryRun", g.DryRun)
+			for _, obj := range o.objects {
+				g.Recorder.Event(obj, corev1.EventTypeWarning, constants.ReasonOrphanFound, fmt.Sprintf("Found orphaned %s", o.message))
+			}
+			continue
+		}
+
+		err := o.collect()
+		if err != nil {
+			g.Log.Error(err, fmt.Sprintf("could not delete orphaned %s", o.message))
+			continue
+		}
+		delete(current, key)
+		gcCollected.WithLabelValues(o.kind).Inc()
+		g.Log.Info(fmt.Sprintf("deleted orphaned %s", o.message))
+		for _, obj := range o.objects {
+			g.Recorder.Event(obj, corev1.EventTypeNormal, constants.ReasonOrphanCollected, fmt.Sprintf("Deleted orphaned %s", o.message))
+		}
+	}
+	g.previous = current
+
+	for kind, count := range counts {
+		gcOrphans.WithLabelValues(kind).Set(float64(count))
+	}
+	return nil
+}
+
+// orphanedPrivateNICs returns the private NICs created by the controller for a PrivateNetwork, tagged with its owner tag,
+// without NetworkInterface
+// All the servers attached to the private network are looked at, including the ones without node or NetworkInterface,
+// e.g. after a crash between the creation of a private NIC and of its NetworkInterface, or a node deleted while the
+// controller was down, but only the private NICs tagged as created for the PrivateNetwork are deleted
+func (g *GarbageCollector) orphanedPrivateNICs(ctx context.Context, pns []vpcv1alpha1.PrivateNetwork, nics []vpcv1alpha1.NetworkInterface, nodes []corev1.Node) []orphan {
+	nodesByServerID := make(map[string]*corev1.Node)
+	for i := range nodes {
+		if serverID, _ := serverIDFromNode(&nodes[i]); serverID != "" {
+			nodesByServerID[serverID] = &nodes[i]
+		}
+	}
+
+	orphans := []orphan{}
+	for i := range pns {
+		pn := &pns[i]
+		// the private NICs of a PrivateNetwork being deleted are removed with its NetworkInterfaces
+		if !pn.ObjectMeta.GetDeletionTimestamp().IsZero() || pn.PrivateNetworkID() == "" {
+			continue
+		}
+		apis, err := g.APIs.ForPrivateNetwork(ctx, pn)
+		if err != nil {
+			g.Log.Error(err, fmt.Sprintf("could not get scaleway apis for privateNetwork %s", pn.Name))
+			continue
+		}
+
+		serversResp, err := apis.InstanceAPI.ListServers(&instance.ListServersRequest{
+			Zone:           scw.Zone(pn.Spec.Zone),
+			PrivateNetwork: scw.StringPtr(pn.PrivateNetworkID()),
+		}, scw.WithAllPages())
+		if err != nil {
+			g.Log.Error(err, fmt.Sprintf("could not list scaleway servers of privateNetwork %s", pn.Name))
+			continue
+		}
+
+		for _, server := range serversResp.Servers {
+			server := server
+			// the SDK doesn't decode the tags of the private NICs yet
+			pnics, err := scwapi.ListPrivateNICs(apis.Client, server.Zone, server.ID)
+			if err != nil {
+				g.Log.Error(err, fmt.Sprintf("could not list private NICs of scaleway server %s", server.ID))
+				continue
+			}
+			node := nodesByServerID[server.ID]
+			objects := []runtime.Object{pn}
+			if node != nil {
+				objects = append(objects, node)
+			}
+
+			for _, pnic := range unownedPrivateNICs(pn, pnics, nics) {
+				pnic := pnic
+				orphans = append(orphans, orphan{
+					kind: orphanKindPrivateNIC,
+					key:  pnic.ID,
+					collect: func() error {
+						err := apis.InstanceAPI.DeletePrivateNIC(&instance.DeletePrivateNICRequest{
+							Zone:         server.Zone,
+							ServerID:     server.ID,
+							PrivateNicID: pnic.ID,
+						})
+						if node != nil {
+							apis.Servers.Invalidate(node)
+						}
+						if err != nil && scwapi.Classify(err) != scwapi.ErrorClassNotFound {
+							return err
+						}
+						return nil
+					},
+					objects: objects,
+					message: fmt.Sprintf("private NIC %s of server %s", pnic.ID, server.ID),
+				})
+			}
+		}
+	}
+	return orphans
+}
+
+// unownedPrivateNICs returns the private NICs attached to the private network of the PrivateNetwork, tagged with its
+// owner tag, without NetworkInterface
+func unownedPrivateNICs(pn *vpcv1alpha1.PrivateNetwork, pnics []*scwapi.PrivateNIC, nics []vpcv1alpha1.NetworkInterface) []*scwapi.PrivateNIC {
+	owned := make(map[string]bool)
+	for _, nic := range nics {
+		owned[nic.Spec.ID] = true
+	}
+
+	tag := ownerTag(pn)
+	unowned := []*scwapi.PrivateNIC{}
+	for _, pnic := range pnics {
+		if pnic.PrivateNetworkID == pn.PrivateNetworkID() && pnic.HasTag(tag) && !owned[pnic.ID] {
+			unowned = append(unowned, pnic)
+		}
+	}
+	return unowned
+}
+
+// orphanedIPAMEntries returns the IPAM addresses without NetworkInterface, and the IPAM prefixes without PrivateNetwork
+// A prefix is in use as long as it is a range of a PrivateNetwork, the parent CIDR of a NetworkInterface or it contains
+// the address of a NetworkInterface or of a Public Gateway, so that changing the ranges of a PrivateNetwork, or a Subnet IPAM without
+// status.cidr yet, never releases the addresses in use
+func (g *GarbageCollector) orphanedIPAMEntries(pns []vpcv1alpha1.PrivateNetwork, nics []vpcv1alpha1.NetworkInterface) ([]orphan, error) {
+	pnsByCIDR := make(map[string]*vpcv1alpha1.PrivateNetwork)
+	for i := range pns {
+		for _, cidr := range pns[i].IPAMRanges() {
+			pnsByCIDR[cidr] = &pns[i]
+		}
+	}
+
+	usedPrefixes := make(map[string]bool)
+	usedIPs := []net.IP{}
+	// the address of the Public Gateway is acquired by the PrivateNetwork
+	for _, pn := range pns {
+		if ip := net.ParseIP(pn.Status.GatewayAddress); ip != nil {
+			usedIPs = append(usedIPs, ip)
+		}
+	}
+	for _, nic := range nics {
+		if nic.Status.ParentCIDR != "" {
+			usedPrefixes[nic.Status.ParentCIDR] = true
+		}
+		for _, address := range []string{nic.Spec.Address, nic.Status.Address} {
+			if ip := net.ParseIP(strings.Split(address, "/")[0]); ip != nil {
+				usedIPs = append(usedIPs, ip)
+			}
+		}
+	}
+
+	prefixes, err := g.Storage.ReadAllPrefixes()
+	if err != nil {
+		return nil, fmt.Errorf("could not read ipam prefixes: %w", err)
+	}
+
+	orphans := []orphan{}
+	for i := range prefixes {
+		prefix := &prefixes[i]
+		ips, err := ipam.PrefixIPs(prefix)
+		if err != nil {
+			return nil, err
+		}
+		cidr := prefix.Cidr
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ipam prefix %s: %w", cidr, err)
+		}
+
+		// the addresses of the prefix used by a NetworkInterface
+		used := make(map[string]bool)
+		for _, ip := range usedIPs {
+			if ipNet.Contains(ip) {
+				used[ip.String()] = true
+			}
+		}
+
+		pn, ok := pnsByCIDR[cidr]
+		if !ok {
+			if usedPrefixes[cidr] || len(used) != 0 {
+				continue
+			}
+			orphans = append(orphans, orphan{
+				kind: orphanKindPrefix,
+				key:  cidr,
+				collect: func() error {
+					// go-ipam doesn't delete a prefix with addresses
+					for _, ip := range ips {
+						err := g.IPAM.ReleaseIPFromPrefix(cidr, ip)
+						if err != nil && !errors.As(err, &goipam.NotFoundError{}) {
+							return err
+						}
+					}
+					_, err := g.IPAM.DeletePrefix(cidr)
+					return err
+				},
+				message: fmt.Sprintf("prefix %s with %d addresses", cidr, len(ips)),
+			})
+			continue
+		}
+
+		for _, ip := range ips {
+			if used[ip] {
+				continue
+			}
+			ip := ip
+			orphans = append(orphans, orphan{
+				kind: orphanKindIP,
+				key:  cidr + "/" + ip,
+				collect: func() error {
+					err := g.IPAM.ReleaseIPFromPrefix(cidr, ip)
+					if err != nil && !errors.As(err, &goipam.NotFoundError{}) {
+						return err
+					}
+					return nil
+				},
+				objects: []runtime.Object{pn},
+				message: fmt.Sprintf("IP %s in %s", ip, cidr),
+			})
+		}
+	}
+	return orphans, nil
+}
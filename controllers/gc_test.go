@@ -0,0 +1,283 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	goipam "github.com/metal-stack/go-ipam"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/scwapi"
+)
+
+func staticPrivateNetwork(name string, ipamType vpcv1alpha1.IPAMType, cidr string, ranges ...string) vpcv1alpha1.PrivateNetwork {
+	return vpcv1alpha1.PrivateNetwork{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: vpcv1alpha1.PrivateNetworkSpec{
+			ID: name + "-id",
+			IPAM: &vpcv1alpha1.PrivateNetworkIPAM{
+				Type: ipamType,
+				Static: &vpcv1alpha1.PrivateNetworkIPAMStatic{
+					CIDR:            cidr,
+					AvailableRanges: ranges,
+				},
+			},
+		},
+	}
+}
+
+func networkInterface(pnName, nodeName, id, address, parentCIDR string) vpcv1alpha1.NetworkInterface {
+	return vpcv1alpha1.NetworkInterface{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: pnName + "-" + nodeName,
+			Labels: map[string]string{
+				constants.PrivateNetworkLabel: pnName,
+				constants.NodeLabel:           nodeName,
+			},
+		},
+		Spec: vpcv1alpha1.NetworkInterfaceSpec{
+			ID: id,
+		},
+		Status: vpcv1alpha1.NetworkInterfaceStatus{
+			Address:    address,
+			ParentCIDR: parentCIDR,
+		},
+	}
+}
+
+func TestOrphanedIPAMEntries(t *testing.T) {
+	tests := []struct {
+		name     string
+		pns      []vpcv1alpha1.PrivateNetwork
+		nics     []vpcv1alpha1.NetworkInterface
+		prefixes map[string][]string
+		expected []string
+	}{
+		{
+			name: "all used",
+			pns:  []vpcv1alpha1.PrivateNetwork{staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeStatic, "10.0.0.0/24")},
+			nics: []vpcv1alpha1.NetworkInterface{
+				networkInterface("pn", "node-1", "nic-1", "10.0.0.1/24", "10.0.0.0/24"),
+				networkInterface("pn", "node-2", "nic-2", "10.0.0.2/24", "10.0.0.0/24"),
+			},
+			prefixes: map[string][]string{"10.0.0.0/24": {"10.0.0.1", "10.0.0.2"}},
+			expected: []string{},
+		},
+		{
+			name:     "unused ip",
+			pns:      []vpcv1alpha1.PrivateNetwork{staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeStatic, "10.0.0.0/24")},
+			nics:     []vpcv1alpha1.NetworkInterface{networkInterface("pn", "node-1", "nic-1", "10.0.0.1/24", "10.0.0.0/24")},
+			prefixes: map[string][]string{"10.0.0.0/24": {"10.0.0.1", "10.0.0.3"}},
+			expected: []string{"ip/10.0.0.0/24/10.0.0.3"},
+		},
+		{
+			name: "gateway address",
+			pns: []vpcv1alpha1.PrivateNetwork{func() vpcv1alpha1.PrivateNetwork {
+				pn := staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeStatic, "10.0.0.0/24")
+				pn.Status.GatewayAddress = "10.0.0.254"
+				return pn
+			}()},
+			prefixes: map[string][]string{"10.0.0.0/24": {"10.0.0.254"}},
+			expected: []string{},
+		},
+		{
+			name:     "prefix without privateNetwork",
+			prefixes: map[string][]string{"10.1.0.0/24": {"10.1.0.1"}},
+			expected: []string{"prefix/10.1.0.0/24"},
+		},
+		{
+			name:     "removed available range with used address",
+			pns:      []vpcv1alpha1.PrivateNetwork{staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeStatic, "10.0.0.0/16", "10.0.2.0/24")},
+			nics:     []vpcv1alpha1.NetworkInterface{networkInterface("pn", "node-1", "nic-1", "10.0.1.1/16", "")},
+			prefixes: map[string][]string{"10.0.1.0/24": {"10.0.1.1", "10.0.1.2"}},
+			expected: []string{},
+		},
+		{
+			name:     "parent cidr of a networkInterface",
+			pns:      []vpcv1alpha1.PrivateNetwork{staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeStatic, "10.0.0.0/16", "10.0.2.0/24")},
+			nics:     []vpcv1alpha1.NetworkInterface{networkInterface("pn", "node-1", "nic-1", "", "10.0.1.0/24")},
+			prefixes: map[string][]string{"10.0.1.0/24": {}},
+			expected: []string{},
+		},
+		{
+			name:     "subnet without status cidr",
+			pns:      []vpcv1alpha1.PrivateNetwork{staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeSubnet, "")},
+			nics:     []vpcv1alpha1.NetworkInterface{networkInterface("pn", "node-1", "nic-1", "172.16.0.2/22", "")},
+			prefixes: map[string][]string{"172.16.0.0/22": {"172.16.0.2"}},
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := goipam.NewMemory()
+			ipamer := goipam.NewWithStorage(storage)
+			for cidr, ips := range tt.prefixes {
+				_, err := ipamer.NewPrefix(cidr)
+				if err != nil {
+					t.Fatalf("could not create prefix %s: %s", cidr, err)
+				}
+				for _, ip := range ips {
+					_, err := ipamer.AcquireSpecificIP(cidr, ip)
+					if err != nil {
+						t.Fatalf("could not acquire %s: %s", ip, err)
+					}
+				}
+			}
+
+			g := &GarbageCollector{
+				IPAM:    ipamer,
+				Storage: storage,
+			}
+			orphans, err := g.orphanedIPAMEntries(tt.pns, tt.nics)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			keys := []string{}
+			for _, o := range orphans {
+				keys = append(keys, o.kind+"/"+o.key)
+				err := o.collect()
+				if err != nil {
+					t.Errorf("could not collect %s: %s", o.message, err)
+				}
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(keys, tt.expected) {
+				t.Errorf("expected orphans %v, got %v", tt.expected, keys)
+			}
+
+			// the collected entries are not found again
+			orphans, err = g.orphanedIPAMEntries(tt.pns, tt.nics)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(orphans) != 0 {
+				t.Errorf("expected no orphans after collection, got %d", len(orphans))
+			}
+		})
+	}
+}
+
+func TestUnownedPrivateNICs(t *testing.T) {
+	pn := staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeStatic, "10.0.0.0/24")
+	pn.UID = "uid"
+	pnics := []*scwapi.PrivateNIC{
+		{ID: "owned", PrivateNetworkID: "pn-id", Tags: []string{ownerTag(&pn)}},
+		{ID: "tagged", PrivateNetworkID: "pn-id", Tags: []string{ownerTag(&pn)}},
+		{ID: "untagged", PrivateNetworkID: "pn-id"},
+		{ID: "other-owner", PrivateNetworkID: "pn-id", Tags: []string{constants.OwnerTagPrefix + "other-uid"}},
+		{ID: "other-network", PrivateNetworkID: "other-id", Tags: []string{ownerTag(&pn)}},
+	}
+
+	tests := []struct {
+		name     string
+		nics     []vpcv1alpha1.NetworkInterface
+		expected []string
+	}{
+		{
+			name:     "tagged without networkInterface",
+			nics:     []vpcv1alpha1.NetworkInterface{networkInterface("pn", "node", "owned", "", "")},
+			expected: []string{"tagged"},
+		},
+		{
+			name:     "no networkInterface",
+			expected: []string{"owned", "tagged"},
+		},
+		{
+			name: "owned by another privateNetwork",
+			nics: []vpcv1alpha1.NetworkInterface{
+				networkInterface("pn", "node", "owned", "", ""),
+				networkInterface("other", "node", "tagged", "", ""),
+			},
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := []string{}
+			for _, pnic := range unownedPrivateNICs(&pn, pnics, tt.nics) {
+				ids = append(ids, pnic.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, ids)
+			}
+		})
+	}
+}
+
+func TestOrphanedPrivateNICs(t *testing.T) {
+	pn := staticPrivateNetwork("pn", vpcv1alpha1.IPAMTypeStatic, "10.0.0.0/24")
+	pn.UID = "uid"
+	tag := ownerTag(&pn)
+	fake := &fakeServers{
+		pnics: map[string][]*scwapi.PrivateNIC{
+			// the node of server-1 has a NetworkInterface
+			"server-1": {
+				{ID: "owned", ServerID: "server-1", PrivateNetworkID: "pn-id", Tags: []string{tag}},
+				{ID: "duplicate", ServerID: "server-1", PrivateNetworkID: "pn-id", Tags: []string{tag}},
+			},
+			// the node of server-2 has no NetworkInterface
+			"server-2": {
+				{ID: "without-nic", ServerID: "server-2", PrivateNetworkID: "pn-id", Tags: []string{tag}},
+			},
+			// server-3 has no node anymore
+			"server-3": {
+				{ID: "without-node", ServerID: "server-3", PrivateNetworkID: "pn-id", Tags: []string{tag}},
+				{ID: "user", ServerID: "server-3", PrivateNetworkID: "pn-id"},
+			},
+		},
+	}
+	nodes := []corev1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}, Spec: corev1.NodeSpec{ProviderID: "scaleway://instance/fr-par-1/server-1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}, Spec: corev1.NodeSpec{ProviderID: "scaleway://instance/fr-par-1/server-2"}},
+	}
+	nics := []vpcv1alpha1.NetworkInterface{networkInterface("pn", "node-1", "owned", "", "")}
+
+	g := &GarbageCollector{
+		APIs: newTestAPIsCache(t, fake),
+		Log:  ctrl.Log,
+	}
+
+	orphans := g.orphanedPrivateNICs(context.Background(), []vpcv1alpha1.PrivateNetwork{pn}, nics, nodes)
+	keys := []string{}
+	objects := map[string]int{}
+	for _, o := range orphans {
+		keys = append(keys, o.key)
+		objects[o.key] = len(o.objects)
+		err := o.collect()
+		if err != nil {
+			t.Errorf("could not collect %s: %s", o.message, err)
+		}
+	}
+	sort.Strings(keys)
+	expected := []string{"duplicate", "without-nic", "without-node"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected orphans %v, got %v", expected, keys)
+	}
+	// the events are recorded on the node only when there is one
+	expectedObjects := map[string]int{"duplicate": 2, "without-nic": 2, "without-node": 1}
+	if !reflect.DeepEqual(objects, expectedObjects) {
+		t.Errorf("expected objects %v, got %v", expectedObjects, objects)
+	}
+	sort.Strings(fake.deleted)
+	if !reflect.DeepEqual(fake.deleted, expected) {
+		t.Errorf("expected deleted private NICs %v, got %v", expected, fake.deleted)
+	}
+
+	// the private NICs of a PrivateNetwork being deleted are left to its NetworkInterfaces
+	deleting := pn.DeepCopy()
+	deleting.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	fake.pnics["server-4"] = []*scwapi.PrivateNIC{{ID: "deleting", ServerID: "server-4", PrivateNetworkID: "pn-id", Tags: []string{tag}}}
+	if orphans := g.orphanedPrivateNICs(context.Background(), []vpcv1alpha1.PrivateNetwork{*deleting}, nil, nodes); len(orphans) != 0 {
+		t.Errorf("expected no orphans for a deleted privateNetwork, got %d", len(orphans))
+	}
+}
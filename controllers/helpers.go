@@ -8,9 +8,8 @@ import (
 	corev1 "k8s.io/api/core/v1"
 )
 
-func getServerFromNode(instanceAPI *instance.API, node *corev1.Node) (*instance.Server, error) {
-	instanceID := ""
-	zone := ""
+// serverIDFromNode returns the ID and the zone of the server of the node from its provider ID, if set
+func serverIDFromNode(node *corev1.Node) (instanceID string, zone string) {
 	if node.Spec.ProviderID != "" {
 		providerID := node.Spec.ProviderID
 		if providerIDRegexp.MatchString(providerID) {
@@ -30,7 +29,11 @@ func getServerFromNode(instanceAPI *instance.API, node *corev1.Node) (*instance.
 			}
 		}
 	}
+	return instanceID, zone
+}
 
+func getServerFromNode(instanceAPI *instance.API, node *corev1.Node) (*instance.Server, error) {
+	instanceID, zone := serverIDFromNode(node)
 	if instanceID != "" {
 		serverResp, err := instanceAPI.GetServer(&instance.GetServerRequest{
 			Zone:     scw.Zone(zone),
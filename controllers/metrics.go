@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	goipam "github.com/metal-stack/go-ipam"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+const metricsNamespace = "scaleway_k8s_vpc"
+
+var (
+	ipamAllocationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "ipam",
+		Name:      "allocation_failures_total",
+		Help:      "Number of failed IP allocations per PrivateNetwork",
+	}, []string{"privatenetwork"})
+
+	ipamPrivateNetworkSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "ipam", "privatenetwork_size"),
+		"Number of addresses in the ranges of a PrivateNetwork",
+		[]string{"privatenetwork"}, nil,
+	)
+	ipamPrivateNetworkAllocatedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "ipam", "privatenetwork_allocated_addresses"),
+		"Number of allocated addresses in the ranges of a PrivateNetwork",
+		[]string{"privatenetwork"}, nil,
+	)
+	ipamPrivateNetworkFreeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "ipam", "privatenetwork_free_addresses"),
+		"Number of free addresses in the ranges of a PrivateNetwork",
+		[]string{"privatenetwork"}, nil,
+	)
+	ipamRangeSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "ipam", "range_size"),
+		"Number of addresses in a range of a PrivateNetwork",
+		[]string{"privatenetwork", "range"}, nil,
+	)
+	ipamRangeAllocatedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "ipam", "range_allocated_addresses"),
+		"Number of allocated addresses in a range of a PrivateNetwork",
+		[]string{"privatenetwork", "range"}, nil,
+	)
+	ipamRangeFreeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "ipam", "range_free_addresses"),
+		"Number of free addresses in a range of a PrivateNetwork",
+		[]string{"privatenetwork", "range"}, nil,
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(ipamAllocationFailures)
+}
+
+// IPAMCollector collects the usage of the IPAM prefixes of the PrivateNetworks
+type IPAMCollector struct {
+	Client client.Client
+	Log    logr.Logger
+	IPAM   goipam.Ipamer
+}
+
+// Describe implements prometheus.Collector
+func (c *IPAMCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ipamPrivateNetworkSizeDesc
+	ch <- ipamPrivateNetworkAllocatedDesc
+	ch <- ipamPrivateNetworkFreeDesc
+	ch <- ipamRangeSizeDesc
+	ch <- ipamRangeAllocatedDesc
+	ch <- ipamRangeFreeDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *IPAMCollector) Collect(ch chan<- prometheus.Metric) {
+	pnsList := &vpcv1alpha1.PrivateNetworkList{}
+	err := c.Client.List(context.Background(), pnsList)
+	if err != nil {
+		c.Log.Error(err, "unable to list privateNetworks for metrics")
+		return
+	}
+
+	for _, pn := range pnsList.Items {
+		ranges := pn.IPAMRanges()
+		if len(ranges) == 0 {
+			continue
+		}
+
+		var size, allocated uint64
+		for _, cidr := range ranges {
+			prefix := c.IPAM.PrefixFrom(cidr)
+			if prefix == nil {
+				// the prefix is created on the first allocation
+				continue
+			}
+			usage := prefix.Usage()
+			size += usage.AvailableIPs
+			allocated += usage.AcquiredIPs
+
+			ch <- prometheus.MustNewConstMetric(ipamRangeSizeDesc, prometheus.GaugeValue, float64(usage.AvailableIPs), pn.Name, cidr)
+			ch <- prometheus.MustNewConstMetric(ipamRangeAllocatedDesc, prometheus.GaugeValue, float64(usage.AcquiredIPs), pn.Name, cidr)
+			ch <- prometheus.MustNewConstMetric(ipamRangeFreeDesc, prometheus.GaugeValue, float64(usage.AvailableIPs-usage.AcquiredIPs), pn.Name, cidr)
+		}
+
+		ch <- prometheus.MustNewConstMetric(ipamPrivateNetworkSizeDesc, prometheus.GaugeValue, float64(size), pn.Name)
+		ch <- prometheus.MustNewConstMetric(ipamPrivateNetworkAllocatedDesc, prometheus.GaugeValue, float64(allocated), pn.Name)
+		ch <- prometheus.MustNewConstMetric(ipamPrivateNetworkFreeDesc, prometheus.GaugeValue, float64(size-allocated), pn.Name)
+	}
+}
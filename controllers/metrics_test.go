@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	goipam "github.com/metal-stack/go-ipam"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+func TestIPAMCollector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = vpcv1alpha1.AddToScheme(scheme)
+
+	static := staticPrivateNetwork("static", vpcv1alpha1.IPAMTypeStatic, "10.0.0.0/16", "10.0.1.0/29", "10.0.2.0/29", "10.0.3.0/29")
+	dhcp := reconciledPrivateNetwork("dhcp", "")
+	dhcp.Spec.IPAM = &vpcv1alpha1.PrivateNetworkIPAM{Type: vpcv1alpha1.IPAMTypeDHCP}
+
+	ipamer := goipam.New()
+	acquired := map[string][]string{
+		"10.0.1.0/29": {"10.0.1.1", "10.0.1.2"},
+		"10.0.2.0/29": {},
+		// not a range of a PrivateNetwork anymore
+		"10.0.4.0/29": {"10.0.4.1"},
+	}
+	for cidr, ips := range acquired {
+		_, err := ipamer.NewPrefix(cidr)
+		if err != nil {
+			t.Fatalf("could not create prefix %s: %s", cidr, err)
+		}
+		for _, ip := range ips {
+			_, err := ipamer.AcquireSpecificIP(cidr, ip)
+			if err != nil {
+				t.Fatalf("could not acquire %s: %s", ip, err)
+			}
+		}
+	}
+
+	collector := &IPAMCollector{
+		Client: fake.NewFakeClientWithScheme(scheme, &static, dhcp),
+		Log:    ctrl.Log.WithName("test"),
+		IPAM:   ipamer,
+	}
+
+	// a /29 has 8 addresses, the network and broadcast addresses are acquired with the prefix,
+	// 10.0.3.0/29 is skipped until its first allocation creates the prefix
+	expected := `
+# HELP scaleway_k8s_vpc_ipam_privatenetwork_allocated_addresses Number of allocated addresses in the ranges of a PrivateNetwork
+# TYPE scaleway_k8s_vpc_ipam_privatenetwork_allocated_addresses gauge
+scaleway_k8s_vpc_ipam_privatenetwork_allocated_addresses{privatenetwork="static"} 6
+# HELP scaleway_k8s_vpc_ipam_privatenetwork_free_addresses Number of free addresses in the ranges of a PrivateNetwork
+# TYPE scaleway_k8s_vpc_ipam_privatenetwork_free_addresses gauge
+scaleway_k8s_vpc_ipam_privatenetwork_free_addresses{privatenetwork="static"} 10
+# HELP scaleway_k8s_vpc_ipam_privatenetwork_size Number of addresses in the ranges of a PrivateNetwork
+# TYPE scaleway_k8s_vpc_ipam_privatenetwork_size gauge
+scaleway_k8s_vpc_ipam_privatenetwork_size{privatenetwork="static"} 16
+# HELP scaleway_k8s_vpc_ipam_range_allocated_addresses Number of allocated addresses in a range of a PrivateNetwork
+# TYPE scaleway_k8s_vpc_ipam_range_allocated_addresses gauge
+scaleway_k8s_vpc_ipam_range_allocated_addresses{privatenetwork="static",range="10.0.1.0/29"} 4
+scaleway_k8s_vpc_ipam_range_allocated_addresses{privatenetwork="static",range="10.0.2.0/29"} 2
+# HELP scaleway_k8s_vpc_ipam_range_free_addresses Number of free addresses in a range of a PrivateNetwork
+# TYPE scaleway_k8s_vpc_ipam_range_free_addresses gauge
+scaleway_k8s_vpc_ipam_range_free_addresses{privatenetwork="static",range="10.0.1.0/29"} 4
+scaleway_k8s_vpc_ipam_range_free_addresses{privatenetwork="static",range="10.0.2.0/29"} 6
+# HELP scaleway_k8s_vpc_ipam_range_size Number of addresses in a range of a PrivateNetwork
+# TYPE scaleway_k8s_vpc_ipam_range_size gauge
+scaleway_k8s_vpc_ipam_range_size{privatenetwork="static",range="10.0.1.0/29"} 8
+scaleway_k8s_vpc_ipam_range_size{privatenetwork="static",range="10.0.2.0/29"} 8
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected))
+	if err != nil {
+		t.Error(err)
+	}
+}
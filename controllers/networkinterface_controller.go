@@ -30,9 +30,11 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -41,21 +43,28 @@ import (
 
 	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
 	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/scwapi"
 )
 
 // NetworkInterfaceReconciler reconciles a NetworkInterface object
 type NetworkInterfaceReconciler struct {
 	client.Client
-	Log         logr.Logger
-	Scheme      *runtime.Scheme
-	IPAM        goipam.Ipamer
-	InstanceAPI *instance.API
+	// Reader reads the pods of the nodes being detached from the API server
+	Reader       client.Reader
+	Log          logr.Logger
+	Scheme       *runtime.Scheme
+	IPAM         goipam.Ipamer
+	APIs         *APIsCache
+	APITransport *scwapi.Transport
+	Recorder     record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces,verbs=get;list;watch;patch
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces/status,verbs=get;patch
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=privatenetworks,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
@@ -86,7 +95,8 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 	}
 
 	if nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
-		if nodeDeleted {
+		// the detach of a node being removed starts with its deletion, and waits for it to be drained
+		if nodeDeleted || !node.ObjectMeta.GetDeletionTimestamp().IsZero() {
 			err := r.Client.Delete(ctx, nic)
 			if err != nil {
 				log.Error(err, fmt.Sprintf("failed to delete networkInterface %s", nic.Name))
@@ -94,49 +104,129 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 			}
 			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
 		}
+		// the detach finalizer is only needed when the detach waits for something
+		if (pn.Spec.Detach != nil) != controllerutil.ContainsFinalizer(nic, constants.DetachFinalizerName) {
+			patch := client.MergeFrom(nic.DeepCopy())
+			if pn.Spec.Detach != nil {
+				controllerutil.AddFinalizer(nic, constants.DetachFinalizerName)
+			} else {
+				controllerutil.RemoveFinalizer(nic, constants.DetachFinalizerName)
+			}
+			err := r.Client.Patch(ctx, nic, patch)
+			if err != nil {
+				log.Error(err, fmt.Sprintf("failed to patch networkInterface %s", nic.Name))
+				return ctrl.Result{}, err
+			}
+		}
 		if len(nic.Status.Address) == 0 && pn.Spec.IPAM != nil {
 			switch pn.Spec.IPAM.Type {
 			case vpcv1alpha1.IPAMTypeDHCP:
 				// this case is handled in the node controller
-			case vpcv1alpha1.IPAMTypeStatic:
-				if pn.Spec.IPAM.Static == nil {
+			case vpcv1alpha1.IPAMTypeStatic, vpcv1alpha1.IPAMTypeSubnet, vpcv1alpha1.IPAMTypeReserved:
+				if pn.Spec.IPAM.Type != vpcv1alpha1.IPAMTypeSubnet && (pn.Spec.IPAM.Static == nil || pn.Spec.IPAM.Static.CIDR == "") {
 					return ctrl.Result{}, fmt.Errorf("Static CIDR can't be empty on static ipam mode")
 				}
-				cidrs := []string{pn.Spec.IPAM.Static.CIDR}
-				if len(pn.Spec.IPAM.Static.AvailableRanges) != 0 {
-					cidrs = pn.Spec.IPAM.Static.AvailableRanges
+				if pn.StaticCIDR() == "" {
+					log.Info("waiting for the subnet of the private network")
+					return ctrl.Result{RequeueAfter: RequeueDuration}, nil
 				}
 
+				var apis *APIs
+				var gatewayNetwork *scwapi.GatewayNetwork
+				if pn.Spec.IPAM.Type == vpcv1alpha1.IPAMTypeReserved {
+					// the address is reserved for the mac address of the private NIC
+					if nic.Status.MacAddress == "" {
+						log.Info("mac address not set yet")
+						return ctrl.Result{}, nil
+					}
+					apis, err = r.APIs.ForPrivateNetwork(ctx, &pn)
+					if err != nil {
+						log.Error(err, "could not get scaleway apis for privateNetwork")
+						return ctrl.Result{}, err
+					}
+					gatewayNetwork, err = dhcpGatewayNetwork(apis, &pn)
+					if err != nil {
+						log.Error(err, "could not get the gateway network of the private network")
+						return resultForAPIError(err, r.APITransport.RetryAfter())
+					}
+				}
 				var ip *goipam.IP
 				var chosenCidr string
 
-				for _, cidr := range cidrs {
-					prefix, err := r.IPAM.NewPrefix(cidr)
-					if err != nil {
-						log.Error(err, "error creating new prefix")
-						continue
+				adopted := false
+				if pn.AdoptsAddresses() {
+					if nic.Status.Adoption == nil {
+						// the status update from the node will trigger a new reconcile
+						log.Info("waiting for the node to report the address of the link")
+						return ctrl.Result{}, nil
 					}
-					ip, err = r.IPAM.AcquireIP(prefix.Cidr)
-					if err != nil {
-						log.Error(err, fmt.Sprintf("error acquiring ip for cidr %s", prefix.Cidr))
-						continue
+					if nic.Status.Adoption.Address != "" {
+						ip, chosenCidr, err = r.adoptAddress(ctx, &pn, nic, nic.Status.Adoption.Address)
+						if err != nil {
+							msg := fmt.Sprintf("Could not adopt address %s of networkInterface %s: %s", nic.Status.Adoption.Address, nic.Name, err)
+							r.Recorder.Event(&pn, corev1.EventTypeWarning, constants.ReasonAdoptionConflict, msg)
+							r.Recorder.Event(nic, corev1.EventTypeWarning, constants.ReasonAdoptionConflict, msg)
+							log.Error(err, fmt.Sprintf("unable to adopt address %s", nic.Status.Adoption.Address))
+							return ctrl.Result{}, err
+						}
+						adopted = true
 					}
-					chosenCidr = prefix.Cidr
-					break
 				}
 
 				if ip == nil {
-					err := fmt.Errorf("could not acquire IP")
-					log.Error(err, "error while testing all cidrs")
-					return ctrl.Result{RequeueAfter: RequeueDuration}, err
+					cidrs := pn.IPAMRanges()
+					for _, cidr := range cidrs {
+						prefix, err := r.IPAM.NewPrefix(cidr)
+						if err != nil {
+							log.Error(err, "error creating new prefix")
+							continue
+						}
+						ip, err = r.IPAM.AcquireIP(prefix.Cidr)
+						if err != nil {
+							log.Error(err, fmt.Sprintf("error acquiring ip for cidr %s", prefix.Cidr))
+							continue
+						}
+						chosenCidr = prefix.Cidr
+						break
+					}
+
+					if ip == nil {
+						ipamAllocationFailures.WithLabelValues(pn.Name).Inc()
+						err := fmt.Errorf("could not acquire IP")
+						msg := fmt.Sprintf("Could not acquire IP in %s for networkInterface %s", strings.Join(cidrs, ", "), nic.Name)
+						r.Recorder.Event(&pn, corev1.EventTypeWarning, constants.ReasonIPAMExhausted, msg)
+						r.Recorder.Event(nic, corev1.EventTypeWarning, constants.ReasonIPAMExhausted, msg)
+						log.Error(err, "error while testing all cidrs")
+						return ctrl.Result{RequeueAfter: RequeueDuration}, err
+					}
 				}
 
 				// TODO have a better idea :D
 				patch := client.MergeFrom(nic.DeepCopy())
-				nic.Status.Address = ip.IP.String() + "/" + strings.Split(pn.Spec.IPAM.Static.CIDR, "/")[1]
+				nic.Status.Address = ip.IP.String() + "/" + strings.Split(pn.StaticCIDR(), "/")[1]
 				nic.Status.ParentCIDR = chosenCidr
+				if gatewayNetwork != nil {
+					entry, err := reserveAddress(apis, &pn, gatewayNetwork, nic.Status.MacAddress, ip.IP.String())
+					if err != nil {
+						ipamErr := r.IPAM.ReleaseIPFromPrefix(chosenCidr, ip.IP.String())
+						if ipamErr != nil {
+							log.Error(ipamErr, fmt.Sprintf("failed to release IP %s", nic.Status.Address))
+						}
+						r.Recorder.Event(nic, corev1.EventTypeWarning, constants.ReasonAddressReservationFailed,
+							fmt.Sprintf("Could not reserve IP %s on gateway network %s: %s", ip.IP.String(), gatewayNetwork.ID, err))
+						log.Error(err, fmt.Sprintf("failed to reserve IP %s", nic.Status.Address))
+						return resultForAPIError(err, r.APITransport.RetryAfter())
+					}
+					nic.Status.DHCPEntryID = entry.ID
+					r.Recorder.Event(nic, corev1.EventTypeNormal, constants.ReasonAddressReserved,
+						fmt.Sprintf("Reserved IP %s on gateway network %s", ip.IP.String(), gatewayNetwork.ID))
+				}
 				err = r.Client.Status().Patch(ctx, nic, patch)
 				if err != nil {
+					reservationErr := releaseReservation(apis, &pn, nic)
+					if reservationErr != nil {
+						log.Error(reservationErr, fmt.Sprintf("failed to delete reservation of IP %s", nic.Status.Address))
+					}
 					ipamErr := r.IPAM.ReleaseIPFromPrefix(chosenCidr, strings.Split(nic.Status.Address, "/")[0])
 					if ipamErr != nil {
 						log.Error(ipamErr, fmt.Sprintf("failed to release IP %s", nic.Status.Address))
@@ -144,6 +234,11 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 					log.Error(err, fmt.Sprintf("failed to update networkInterface %s", nic.Name))
 					return ctrl.Result{}, err
 				}
+				if adopted {
+					r.Recorder.Event(nic, corev1.EventTypeNormal, constants.ReasonIPAdopted, fmt.Sprintf("Adopted IP %s in %s", nic.Status.Address, chosenCidr))
+				} else {
+					r.Recorder.Event(nic, corev1.EventTypeNormal, constants.ReasonIPAcquired, fmt.Sprintf("Acquired IP %s in %s", nic.Status.Address, chosenCidr))
+				}
 			default:
 				return ctrl.Result{}, fmt.Errorf("IPAM type %s is not supported", pn.Spec.IPAM.Type)
 			}
@@ -154,6 +249,13 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 
 	// nic is deleting
 
+	if controllerutil.ContainsFinalizer(nic, constants.DetachFinalizerName) {
+		if nodeDeleted {
+			return r.reconcileDetach(ctx, log, nic, &pn, nil)
+		}
+		return r.reconcileDetach(ctx, log, nic, &pn, &node)
+	}
+
 	if controllerutil.ContainsFinalizer(nic, constants.FinalizerName) && nodeDeleted {
 		patch := client.MergeFrom(nic.DeepCopy())
 		controllerutil.RemoveFinalizer(nic, constants.FinalizerName)
@@ -166,12 +268,27 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 	}
 
 	if !controllerutil.ContainsFinalizer(nic, constants.FinalizerName) {
-		if pn.Spec.IPAM != nil && pn.Spec.IPAM.Type == vpcv1alpha1.IPAMTypeStatic {
-			if pn.Spec.IPAM.Static == nil {
-				return ctrl.Result{}, fmt.Errorf("Static CIDR can't be empty on static ipam mode")
+		if pn.IsStaticIPAM() && nic.Status.Address != "" {
+			if nic.Status.DHCPEntryID != "" {
+				apis, err := r.APIs.ForPrivateNetwork(ctx, &pn)
+				if err != nil {
+					log.Error(err, "could not get scaleway apis for privateNetwork")
+					return ctrl.Result{}, err
+				}
+				patch := client.MergeFrom(nic.DeepCopy())
+				err = releaseReservation(apis, &pn, nic)
+				if err != nil {
+					log.Error(err, fmt.Sprintf("could not delete reservation of IP %s", nic.Status.Address))
+					return resultForAPIError(err, r.APITransport.RetryAfter())
+				}
+				// the reservation is not deleted again if the release of the IP fails
+				err = r.Client.Status().Patch(ctx, nic, patch)
+				if err != nil {
+					log.Error(err, fmt.Sprintf("failed to update networkInterface %s", nic.Name))
+					return ctrl.Result{}, err
+				}
 			}
-
-			cidr := pn.Spec.IPAM.Static.CIDR
+			cidr := pn.StaticCIDR()
 			if nic.Status.ParentCIDR != "" {
 				cidr = nic.Status.ParentCIDR
 			}
@@ -181,6 +298,8 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 					log.Error(err, fmt.Sprintf("could not delete IP %s from prefix %s", nic.Status.Address, cidr))
 					return ctrl.Result{}, err
 				}
+			} else {
+				r.Recorder.Event(nic, corev1.EventTypeNormal, constants.ReasonIPReleased, fmt.Sprintf("Released IP %s from %s", nic.Status.Address, cidr))
 			}
 		}
 		node := corev1.Node{}
@@ -190,28 +309,41 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 			return ctrl.Result{}, err
 		}
 		if err == nil {
-			server, err := getServerFromNode(r.InstanceAPI, &node)
+			apis, err := r.APIs.ForPrivateNetwork(ctx, &pn)
 			if err != nil {
-				log.Error(err, "error getting server from node")
+				log.Error(err, "could not get scaleway apis for privateNetwork")
 				return ctrl.Result{}, err
 			}
+			server, err := apis.Servers.Get(&node)
+			if err != nil {
+				log.Error(err, "error getting server from node")
+				return resultForAPIError(err, r.APITransport.RetryAfter())
+			}
 			privateNicID := ""
 			for _, pnic := range server.PrivateNics {
-				if pnic.PrivateNetworkID == pn.Spec.ID {
+				if pnic.PrivateNetworkID == pn.PrivateNetworkID() {
 					privateNicID = pnic.ID
 					break
 				}
 			}
 			if privateNicID != "" {
-				err := r.InstanceAPI.DeletePrivateNIC(&instance.DeletePrivateNICRequest{
+				err := apis.InstanceAPI.DeletePrivateNIC(&instance.DeletePrivateNICRequest{
 					Zone:         server.Zone,
 					PrivateNicID: privateNicID,
 					ServerID:     server.ID,
 				})
-				if err != nil {
+				apis.Servers.Invalidate(&node)
+				// the private NIC may already be gone, e.g. deleted by hand
+				if err != nil && scwapi.Classify(err) != scwapi.ErrorClassNotFound {
+					msg := fmt.Sprintf("Could not delete private NIC %s from server %s: %s", privateNicID, server.ID, err)
+					r.Recorder.Event(nic, corev1.EventTypeWarning, constants.ReasonPrivateNICDeletionFailed, msg)
+					r.Recorder.Event(&node, corev1.EventTypeWarning, constants.ReasonPrivateNICDeletionFailed, msg)
 					log.Error(err, "unable to delete private nic from server")
-					return ctrl.Result{}, err
+					return resultForAPIError(err, r.APITransport.RetryAfter())
 				}
+				msg := fmt.Sprintf("Deleted private NIC %s from server %s", privateNicID, server.ID)
+				r.Recorder.Event(&pn, corev1.EventTypeNormal, constants.ReasonPrivateNICDeleted, msg)
+				r.Recorder.Event(&node, corev1.EventTypeNormal, constants.ReasonPrivateNICDeleted, msg)
 			}
 		}
 
@@ -227,31 +359,42 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 	return ctrl.Result{}, nil
 }
 
-func (r *NetworkInterfaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+func (r *NetworkInterfaceReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
 	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(options).
 		For(&vpcv1alpha1.NetworkInterface{}).
 		Watches(&source.Kind{
 			Type: &corev1.Node{},
 		}, &handler.Funcs{
-			DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
-				nicsList := &vpcv1alpha1.NetworkInterfaceList{}
-				err := r.Client.List(context.Background(), nicsList,
-					client.MatchingLabels{
-						constants.NodeLabel: e.Meta.GetName(),
-					},
-				)
-				if err != nil {
-					r.Log.Error(err, "unable to sync privatenetwork on node creation")
-					return
-				}
-				for _, nic := range nicsList.Items {
-					q.Add(reconcile.Request{
-						NamespacedName: types.NamespacedName{
-							Name: nic.Name,
-						},
-					})
+			UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+				if !e.MetaNew.GetDeletionTimestamp().IsZero() && e.MetaOld.GetDeletionTimestamp().IsZero() {
+					r.enqueueNodeNetworkInterfaces(e.MetaNew.GetName(), q)
 				}
 			},
+			DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+				r.enqueueNodeNetworkInterfaces(e.Meta.GetName(), q)
+			},
 		}).
 		Complete(r)
 }
+
+// enqueueNodeNetworkInterfaces adds the NetworkInterfaces of the node to the queue
+func (r *NetworkInterfaceReconciler) enqueueNodeNetworkInterfaces(nodeName string, q workqueue.RateLimitingInterface) {
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := r.Client.List(context.Background(), nicsList,
+		client.MatchingLabels{
+			constants.NodeLabel: nodeName,
+		},
+	)
+	if err != nil {
+		r.Log.Error(err, fmt.Sprintf("unable to list networkInterfaces of node %s", nodeName))
+		return
+	}
+	for _, nic := range nicsList.Items {
+		q.Add(reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name: nic.Name,
+			},
+		})
+	}
+}
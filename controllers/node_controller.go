@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	goipam "github.com/metal-stack/go-ipam"
+	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
+	corev1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+)
+
+// NodeReconciler attaches a new node to all the PrivateNetworks in a single pass,
+// looking its Scaleway server up once for all the PrivateNetworks sharing the same credentials
+type NodeReconciler struct {
+	client.Client
+	Log logr.Logger
+	// PrivateNetworks attaches the node to each PrivateNetwork
+	PrivateNetworks *PrivateNetworkReconciler
+}
+
+func (r *NodeReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("node", req.Name)
+
+	node := &corev1.Node{}
+	err := r.Get(ctx, req.NamespacedName, node)
+	if err != nil {
+		log.Error(err, "could not find object")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// a node being removed is detached by the NetworkInterface controller, and must not be attached again
+	if !node.ObjectMeta.GetDeletionTimestamp().IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	pnsList := &vpcv1alpha1.PrivateNetworkList{}
+	err = r.Client.List(ctx, pnsList)
+	if err != nil {
+		log.Error(err, "could not list privateNetworks")
+		return ctrl.Result{}, err
+	}
+
+	// the server of the node, per APIs since the PrivateNetworks can use different credentials
+	servers := make(map[*APIs]*instance.Server)
+	result := ctrl.Result{}
+	errs := []error{}
+	for i := range pnsList.Items {
+		pn := &pnsList.Items[i]
+		if !nodesReconciled(pn) {
+			continue
+		}
+		pnLog := log.WithValues("privatenetwork", pn.Name)
+
+		nodeErr := r.reconcilePrivateNetwork(ctx, pnLog, pn, node, servers)
+		if nodeErr == nil {
+			continue
+		}
+		res, err := resultForAPIError(nodeErr, r.PrivateNetworks.APITransport.RetryAfter())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not attach privateNetwork %s: %w", pn.Name, err))
+			continue
+		}
+		if result.RequeueAfter == 0 || res.RequeueAfter < result.RequeueAfter {
+			result = res
+		}
+	}
+
+	if len(errs) != 0 {
+		return ctrl.Result{}, utilerrors.NewAggregate(errs)
+	}
+	return result, nil
+}
+
+// reconcilePrivateNetwork attaches pn to the node, looking the server of the node up only if not in servers yet
+func (r *NodeReconciler) reconcilePrivateNetwork(ctx context.Context, log logr.Logger, pn *vpcv1alpha1.PrivateNetwork, node *corev1.Node, servers map[*APIs]*instance.Server) error {
+	apis, err := r.PrivateNetworks.APIs.ForPrivateNetwork(ctx, pn)
+	if err != nil {
+		log.Error(err, "could not get scaleway apis for privateNetwork")
+		return err
+	}
+
+	server, ok := servers[apis]
+	if !ok {
+		server, err = getServer(log, apis, node)
+		if err != nil {
+			return err
+		}
+		servers[apis] = server
+	}
+
+	var prefix *goipam.Prefix
+	if pn.Spec.CIDR != "" {
+		// the prefix of the deprecated CIDR mode is created by the PrivateNetwork reconcile
+		prefix = r.PrivateNetworks.IPAM.PrefixFrom(pn.Spec.CIDR)
+		if prefix == nil {
+			return nil
+		}
+	}
+	return r.PrivateNetworks.reconcileNode(ctx, log, pn, apis, node, server, prefix)
+}
+
+// nodesReconciled returns whether the PrivateNetwork already went through the attachment of the nodes,
+// the new nodes are attached by the PrivateNetwork reconcile otherwise
+func nodesReconciled(pn *vpcv1alpha1.PrivateNetwork) bool {
+	if !pn.ObjectMeta.GetDeletionTimestamp().IsZero() || !controllerutil.ContainsFinalizer(pn, constants.FinalizerName) || pn.PrivateNetworkID() == "" {
+		return false
+	}
+	for _, condition := range pn.Status.Conditions {
+		if condition.Type == vpcv1alpha1.PrivateNetworkReady {
+			return condition.Reason == constants.ReasonReconciled || condition.Reason == constants.ReasonNodesFailed
+		}
+	}
+	return false
+}
+
+func (r *NodeReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(options).
+		For(&corev1.Node{}).
+		// the nodes are attached when they are created, or when their provider ID is set by the cloud controller manager
+		WithEventFilter(predicate.Funcs{
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				oldNode, ok := e.ObjectOld.(*corev1.Node)
+				if !ok {
+					return false
+				}
+				newNode, ok := e.ObjectNew.(*corev1.Node)
+				if !ok {
+					return false
+				}
+				return oldNode.Spec.ProviderID != newNode.Spec.ProviderID
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return false
+			},
+		}).
+		Complete(r)
+}
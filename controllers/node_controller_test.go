@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/scwapi"
+)
+
+func reconciledPrivateNetwork(name, reason string) *vpcv1alpha1.PrivateNetwork {
+	pn := &vpcv1alpha1.PrivateNetwork{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       name,
+			UID:        types.UID(name + "-uid"),
+			Finalizers: []string{constants.FinalizerName},
+		},
+		Status: vpcv1alpha1.PrivateNetworkStatus{
+			ID: name + "-id",
+		},
+	}
+	if reason != "" {
+		pn.Status.Conditions = []vpcv1alpha1.PrivateNetworkCondition{{
+			Type:   vpcv1alpha1.PrivateNetworkReady,
+			Status: corev1.ConditionTrue,
+			Reason: reason,
+		}}
+	}
+	return pn
+}
+
+func TestNodeReconcile(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = vpcv1alpha1.AddToScheme(scheme)
+
+	deleting := reconciledPrivateNetwork("deleting", constants.ReasonReconciled)
+	deleting.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	objs := []runtime.Object{
+		scalewayNode("node", "server"),
+		// already attached at Scaleway, without NetworkInterface yet
+		reconciledPrivateNetwork("attached", constants.ReasonReconciled),
+		reconciledPrivateNetwork("failed", constants.ReasonNodesFailed),
+		// the nodes are attached by the PrivateNetwork reconcile until it went through them once
+		reconciledPrivateNetwork("pending", ""),
+		reconciledPrivateNetwork("invalid", constants.ReasonInvalidCredentials),
+		deleting,
+	}
+	fakeAPI := &fakeServers{
+		pnics: map[string][]*scwapi.PrivateNIC{
+			"server": {{ID: "existing", ServerID: "server", PrivateNetworkID: "attached-id", MacAddress: "02:00:00:00:00:aa"}},
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme, objs...)
+	r := &NodeReconciler{
+		Client: c,
+		Log:    ctrl.Log,
+		PrivateNetworks: &PrivateNetworkReconciler{
+			Client:       c,
+			Reader:       c,
+			Log:          ctrl.Log,
+			Scheme:       scheme,
+			APIs:         newTestAPIsCache(t, fakeAPI),
+			APITransport: &scwapi.Transport{},
+			Recorder:     record.NewFakeRecorder(10),
+		},
+	}
+
+	// the server is looked up once for all the PrivateNetworks, and again only after a private NIC was created
+	for i, expectedGets := range []int{1, 2, 2} {
+		_, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: "node"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if fakeAPI.gets != expectedGets {
+			t.Errorf("expected %d server lookups after reconcile %d, got %d", expectedGets, i+1, fakeAPI.gets)
+		}
+	}
+	if len(fakeAPI.created) != 1 {
+		t.Fatalf("expected 1 private NIC created, got %d", len(fakeAPI.created))
+	}
+	created := fakeAPI.created[0]
+	if created.PrivateNetworkID != "failed-id" || !created.HasTag(constants.OwnerTagPrefix+"failed-uid") {
+		t.Errorf("expected a private NIC in failed-id tagged with its owner, got %+v", created)
+	}
+
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := c.List(context.Background(), nicsList, client.MatchingLabels{constants.NodeLabel: "node"})
+	if err != nil {
+		t.Fatalf("could not list networkInterfaces: %s", err)
+	}
+	nics := []string{}
+	for _, nic := range nicsList.Items {
+		nics = append(nics, nic.Labels[constants.PrivateNetworkLabel]+"/"+nic.Spec.ID+"/"+nic.Status.MacAddress)
+	}
+	sort.Strings(nics)
+	expected := []string{"attached/existing/02:00:00:00:00:aa", "failed/created-1/02:00:00:00:00:01"}
+	if !reflect.DeepEqual(nics, expected) {
+		t.Errorf("expected networkInterfaces %v, got %v", expected, nics)
+	}
+}
+
+func TestNodeReconcileDeletedNode(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = vpcv1alpha1.AddToScheme(scheme)
+
+	node := scalewayNode("node", "server")
+	node.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	fakeAPI := &fakeServers{pnics: map[string][]*scwapi.PrivateNIC{"server": {}}}
+	c := fake.NewFakeClientWithScheme(scheme, node, reconciledPrivateNetwork("pn", constants.ReasonReconciled))
+	r := &NodeReconciler{
+		Client: c,
+		Log:    ctrl.Log,
+		PrivateNetworks: &PrivateNetworkReconciler{
+			Client:       c,
+			Reader:       c,
+			Log:          ctrl.Log,
+			Scheme:       scheme,
+			APIs:         newTestAPIsCache(t, fakeAPI),
+			APITransport: &scwapi.Transport{},
+			Recorder:     record.NewFakeRecorder(10),
+		},
+	}
+
+	_, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: "node"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if fakeAPI.gets != 0 || len(fakeAPI.created) != 0 {
+		t.Errorf("expected a node being deleted not to be attached, got %d lookups and %d private NICs created", fakeAPI.gets, len(fakeAPI.created))
+	}
+}
@@ -20,30 +20,29 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 	goipam "github.com/metal-stack/go-ipam"
 	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
-	vpc "github.com/scaleway/scaleway-sdk-go/api/vpc/v1"
-	"github.com/scaleway/scaleway-sdk-go/scw"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/util/workqueue"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
-	"sigs.k8s.io/controller-runtime/pkg/event"
-	"sigs.k8s.io/controller-runtime/pkg/handler"
-	"sigs.k8s.io/controller-runtime/pkg/reconcile"
-	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
 	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/scwapi"
 )
 
 const (
@@ -59,14 +58,30 @@ var (
 	RequeueDuration time.Duration = time.Second * 30
 )
 
+// DefaultMaxConcurrentNodes is the default number of nodes a PrivateNetwork is attached to in parallel
+const DefaultMaxConcurrentNodes = 4
+
 // PrivateNetworkReconciler reconciles a PrivateNetwork object
 type PrivateNetworkReconciler struct {
 	client.Client
-	Log         logr.Logger
-	Scheme      *runtime.Scheme
-	IPAM        goipam.Ipamer
-	InstanceAPI *instance.API
-	VpcAPI      *vpc.API
+	// Reader reads the NetworkInterfaces from the API server before creating one, the cache could miss one just created
+	Reader       client.Reader
+	Log          logr.Logger
+	Scheme       *runtime.Scheme
+	IPAM         goipam.Ipamer
+	APIs         *APIsCache
+	APITransport *scwapi.Transport
+	Recorder     record.EventRecorder
+
+	// MaxConcurrentNodes is the number of nodes a PrivateNetwork is attached to in parallel
+	MaxConcurrentNodes int
+	// SyncTags enables the managed tags on the Scaleway private networks
+	SyncTags bool
+	// ClusterName is the name of the cluster set in the managed tags
+	ClusterName string
+
+	// nodeLocks holds a mutex per node name, so that the PrivateNetwork and node passes never attach a node at the same time
+	nodeLocks sync.Map
 }
 
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=privatenetworks,verbs=get;list;watch;create;update;patch;delete
@@ -74,7 +89,8 @@ type PrivateNetworkReconciler struct {
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces/status,verbs=get;update
 // +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
-// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *PrivateNetworkReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
@@ -117,6 +133,16 @@ func (r *PrivateNetworkReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 				}
 			}
 			if len(nicsList.Items) == 0 {
+				err = r.deletePrivateNetwork(ctx, log, pn)
+				if err != nil {
+					log.Error(err, "failed to delete scaleway private network")
+					return resultForAPIError(err, r.APITransport.RetryAfter())
+				}
+				err = r.releaseGatewayAddress(pn)
+				if err != nil {
+					log.Error(err, fmt.Sprintf("failed to release gateway address %s", pn.Status.GatewayAddress))
+					return ctrl.Result{}, err
+				}
 				_, err = r.IPAM.DeletePrefix(pn.Spec.CIDR)
 				if err != nil {
 					if !errors.As(err, &goipam.NotFoundError{}) {
@@ -146,96 +172,47 @@ func (r *PrivateNetworkReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 		}
 	}
 
-	_, err = r.VpcAPI.GetPrivateNetwork(&vpc.GetPrivateNetworkRequest{
-		Zone:             scw.Zone(pn.Spec.Zone),
-		PrivateNetworkID: pn.Spec.ID,
-	})
+	apis, err := r.APIs.ForPrivateNetwork(ctx, pn)
 	if err != nil {
-		log.Error(err, "error getting private network from api")
-		return ctrl.Result{RequeueAfter: RequeueDuration}, err
+		log.Error(err, "could not get scaleway apis for privateNetwork")
+		if patchErr := r.setReady(ctx, pn, corev1.ConditionFalse, constants.ReasonInvalidCredentials, err.Error()); patchErr != nil {
+			log.Error(patchErr, "could not patch privateNetwork status")
+		}
+		return ctrl.Result{}, err
 	}
 
-	nodesList := &corev1.NodeList{}
-	err = r.Client.List(ctx, nodesList)
+	_, err = r.ensurePrivateNetwork(ctx, log, pn, apis)
 	if err != nil {
-		log.Error(err, "could not list nodes")
-		return ctrl.Result{RequeueAfter: RequeueDuration}, err
+		log.Error(err, "error getting private network from api")
+		return r.handleAPIError(ctx, pn, err)
 	}
 
-	for _, node := range nodesList.Items {
-		nicsList := &vpcv1alpha1.NetworkInterfaceList{}
-		err = r.Client.List(ctx, nicsList,
-			client.MatchingLabels{
-				constants.PrivateNetworkLabel: pn.Name,
-				constants.NodeLabel:           node.Name,
-			},
-		)
-		if err != nil {
-			log.Error(err, fmt.Sprintf("could not list NetworkInterface for node %s and privateNetwork %s", node.Name, pn.Name))
-			return ctrl.Result{RequeueAfter: RequeueDuration}, err
-		}
-
-		server, err := getServerFromNode(r.InstanceAPI, &node)
+	if pn.Spec.IPAM != nil && pn.Spec.IPAM.Type == vpcv1alpha1.IPAMTypeSubnet {
+		cidr, err := subnetCIDR(pn, pn.Status.Subnets)
 		if err != nil {
-			log.Error(err, fmt.Sprintf("could not get scaleway server from node %s", node.Name))
-			break
-		}
-
-		var privateNIC *instance.PrivateNIC
-		for _, pnic := range server.PrivateNics {
-			if pnic.PrivateNetworkID == pn.Spec.ID {
-				privateNIC = pnic
-				break
+			log.Error(err, "invalid subnet ipam")
+			if patchErr := r.setReady(ctx, pn, corev1.ConditionFalse, constants.ReasonInvalidIPAM, err.Error()); patchErr != nil {
+				log.Error(patchErr, "could not patch privateNetwork status")
 			}
+			// the spec or the private network have to change, which will trigger a new reconcile
+			return ctrl.Result{}, nil
 		}
-		if privateNIC == nil {
-			log.Info("Je fais l'appel à l'API pour ajouter le serveur au private network")
-			pnicResp, err := r.InstanceAPI.CreatePrivateNIC(&instance.CreatePrivateNICRequest{
-				Zone:             server.Zone,
-				PrivateNetworkID: pn.Spec.ID,
-				ServerID:         server.ID,
-			})
-			log.Info("J'ai fait l'appel à l'API pour ajouter le serveur au private network")
-			if err != nil {
-				log.Error(err, fmt.Sprintf("unable to create private on server %s", server.ID))
-				return ctrl.Result{RequeueAfter: RequeueDuration}, err
-			}
-			privateNIC = pnicResp.PrivateNic
-		}
-
-		if len(nicsList.Items) > 1 {
-			log.Error(fmt.Errorf("node %s have %d networkInterfaces instead of at most one", node.Name, len(nicsList.Items)), "could not handle node")
-			return ctrl.Result{RequeueAfter: RequeueDuration}, err
+		err = r.updateStatus(ctx, pn, func(status *vpcv1alpha1.PrivateNetworkStatus) {
+			status.CIDR = cidr
+		})
+		if err != nil {
+			log.Error(err, "could not patch privateNetwork status")
+			return ctrl.Result{}, err
 		}
+	}
 
-		if len(nicsList.Items) == 0 {
-			log.Info("Je construis l'interface réseau privée pour le node")
-			nic, err := r.constructNetworkInterfaceForPrivateNetwork(pn, node.Name)
-			log.Info("J'ai construit l'interface réseau privée pour le node")
-			if err != nil {
-				log.Error(err, "unable to construct networkInterface from privateNetwork")
-				return ctrl.Result{RequeueAfter: RequeueDuration}, err
-			}
-
-			nic.Spec.ID = privateNIC.ID
-			err = r.Client.Create(ctx, nic)
-			if err != nil {
-				log.Error(err, "could not create networkInterface")
-				return ctrl.Result{RequeueAfter: RequeueDuration}, err
-			}
-			patch := client.MergeFrom(nic.DeepCopy())
-			log.Info(fmt.Sprintf("L'adresse MAC est %s", privateNIC.MacAddress))
-			nic.Status.MacAddress = privateNIC.MacAddress
-			err = r.Client.Status().Patch(ctx, nic, patch)
-			if err != nil {
-				log.Error(err, "could not patch networkInterface status")
-				return ctrl.Result{RequeueAfter: RequeueDuration}, err
-			}
-			log.Info(fmt.Sprintf("Successfully created networkInterface %s on node %s", nic.Name, node.Name))
-		}
+	// after the subnet, which is the IPAM range of the gateway address with the Subnet IPAM
+	err = r.reconcileGateway(ctx, log, pn, apis)
+	if err != nil {
+		return r.handleAPIError(ctx, pn, err)
 	}
 
-	return ctrl.Result{}, nil
+	return r.reconcileNodes(ctx, log, pn, apis, nil)
 }
 
 func (r *PrivateNetworkReconciler) ReconcileDeprecated(req ctrl.Request) (ctrl.Result, error) {
@@ -280,6 +257,16 @@ func (r *PrivateNetworkReconciler) ReconcileDeprecated(req ctrl.Request) (ctrl.R
 				}
 			}
 			if len(nicsList.Items) == 0 {
+				err = r.deletePrivateNetwork(ctx, log, pn)
+				if err != nil {
+					log.Error(err, "failed to delete scaleway private network")
+					return resultForAPIError(err, r.APITransport.RetryAfter())
+				}
+				err = r.releaseGatewayAddress(pn)
+				if err != nil {
+					log.Error(err, fmt.Sprintf("failed to release gateway address %s", pn.Status.GatewayAddress))
+					return ctrl.Result{}, err
+				}
 				_, err = r.IPAM.DeletePrefix(pn.Spec.CIDR)
 				if err != nil {
 					if !errors.As(err, &goipam.NotFoundError{}) {
@@ -309,98 +296,293 @@ func (r *PrivateNetworkReconciler) ReconcileDeprecated(req ctrl.Request) (ctrl.R
 		}
 	}
 
-	_, err = r.VpcAPI.GetPrivateNetwork(&vpc.GetPrivateNetworkRequest{
-		Zone:             scw.Zone(pn.Spec.Zone),
-		PrivateNetworkID: pn.Spec.ID,
-	})
+	apis, err := r.APIs.ForPrivateNetwork(ctx, pn)
+	if err != nil {
+		log.Error(err, "could not get scaleway apis for privateNetwork")
+		if patchErr := r.setReady(ctx, pn, corev1.ConditionFalse, constants.ReasonInvalidCredentials, err.Error()); patchErr != nil {
+			log.Error(patchErr, "could not patch privateNetwork status")
+		}
+		return ctrl.Result{}, err
+	}
+
+	_, err = r.ensurePrivateNetwork(ctx, log, pn, apis)
 	if err != nil {
 		log.Error(err, "error getting private network from api")
-		return ctrl.Result{RequeueAfter: RequeueDuration}, err
+		return r.handleAPIError(ctx, pn, err)
 	}
 
+	err = r.reconcileGateway(ctx, log, pn, apis)
+	if err != nil {
+		return r.handleAPIError(ctx, pn, err)
+	}
+
+	return r.reconcileNodes(ctx, log, pn, apis, prefix)
+}
+
+// reconcileNodes attaches the PrivateNetwork to all the nodes, a failure on a node doesn't prevent the others from being attached
+// prefix is only set for the deprecated CIDR mode, where the addresses are allocated by the controller
+// The new nodes are attached to all the PrivateNetworks at once by the NodeReconciler
+func (r *PrivateNetworkReconciler) reconcileNodes(ctx context.Context, log logr.Logger, pn *vpcv1alpha1.PrivateNetwork, apis *APIs, prefix *goipam.Prefix) (ctrl.Result, error) {
 	nodesList := &corev1.NodeList{}
-	err = r.Client.List(ctx, nodesList)
+	err := r.Client.List(ctx, nodesList)
 	if err != nil {
 		log.Error(err, "could not list nodes")
 		return ctrl.Result{RequeueAfter: RequeueDuration}, err
 	}
 
-	for _, node := range nodesList.Items {
-		nicsList := &vpcv1alpha1.NetworkInterfaceList{}
-		err = r.Client.List(ctx, nicsList,
-			client.MatchingLabels{
-				constants.PrivateNetworkLabel: pn.Name,
-				constants.NodeLabel:           node.Name,
-			},
-		)
+	workers := r.MaxConcurrentNodes
+	if workers < 1 {
+		workers = 1
+	}
+
+	nodeErrors := make([]error, len(nodesList.Items))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i := range nodesList.Items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			node := &nodesList.Items[i]
+			// a node being removed is detached by the NetworkInterface controller, and must not be attached again
+			if !node.ObjectMeta.GetDeletionTimestamp().IsZero() {
+				return
+			}
+			nodeLog := log.WithValues("node", node.Name)
+			server, err := getServer(nodeLog, apis, node)
+			if err != nil {
+				nodeErrors[i] = err
+				return
+			}
+			nodeErrors[i] = r.reconcileNode(ctx, nodeLog, pn, apis, node, server, prefix)
+		}(i)
+	}
+	wg.Wait()
+
+	result := ctrl.Result{}
+	errs := []error{}
+	failedNodes := []vpcv1alpha1.PrivateNetworkNodeError{}
+	for i, nodeErr := range nodeErrors {
+		if nodeErr == nil {
+			continue
+		}
+		failedNodes = append(failedNodes, vpcv1alpha1.PrivateNetworkNodeError{
+			Node:    nodesList.Items[i].Name,
+			Reason:  reasonForAPIError(nodeErr),
+			Message: nodeErr.Error(),
+		})
+		res, err := resultForAPIError(nodeErr, r.APITransport.RetryAfter())
 		if err != nil {
-			log.Error(err, fmt.Sprintf("could not list NetworkInterface for node %s and privateNetwork %s", node.Name, pn.Name))
-			return ctrl.Result{RequeueAfter: RequeueDuration}, err
+			errs = append(errs, err)
+			continue
+		}
+		if result.RequeueAfter == 0 || res.RequeueAfter < result.RequeueAfter {
+			result = res
 		}
+	}
+	sort.Slice(failedNodes, func(i, j int) bool {
+		return failedNodes[i].Node < failedNodes[j].Node
+	})
 
-		server, err := getServerFromNode(r.InstanceAPI, &node)
-		if err != nil {
-			log.Error(err, fmt.Sprintf("could not get scaleway server from node %s", node.Name))
-			break
+	err = r.updateStatus(ctx, pn, func(status *vpcv1alpha1.PrivateNetworkStatus) {
+		status.FailedNodes = failedNodes
+		if len(failedNodes) == 0 {
+			setPrivateNetworkCondition(status, vpcv1alpha1.PrivateNetworkCondition{
+				Type:   vpcv1alpha1.PrivateNetworkReady,
+				Status: corev1.ConditionTrue,
+				Reason: constants.ReasonReconciled,
+			})
+			return
+		}
+		names := make([]string, 0, len(failedNodes))
+		for _, failed := range failedNodes {
+			names = append(names, failed.Node)
 		}
+		setPrivateNetworkCondition(status, vpcv1alpha1.PrivateNetworkCondition{
+			Type:    vpcv1alpha1.PrivateNetworkReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  constants.ReasonNodesFailed,
+			Message: fmt.Sprintf("%d/%d nodes failed: %s", len(failedNodes), len(nodesList.Items), strings.Join(names, ", ")),
+		})
+	})
+	if err != nil {
+		log.Error(err, "could not patch privateNetwork status")
+		return ctrl.Result{}, err
+	}
 
-		var privateNIC *instance.PrivateNIC
-		for _, pnic := range server.PrivateNics {
-			if pnic.PrivateNetworkID == pn.Spec.ID {
-				privateNIC = pnic
-				break
-			}
+	if len(errs) != 0 {
+		return ctrl.Result{}, utilerrors.NewAggregate(errs)
+	}
+	return result, nil
+}
+
+// getServer returns the Scaleway server of the node, through the server cache of apis
+func getServer(log logr.Logger, apis *APIs, node *corev1.Node) (*instance.Server, error) {
+	server, err := apis.Servers.Get(node)
+	if err != nil {
+		log.Error(err, fmt.Sprintf("could not get scaleway server from node %s", node.Name))
+		return nil, fmt.Errorf("could not get scaleway server: %w", err)
+	}
+	return server, nil
+}
+
+// reconcileNode attaches the PrivateNetwork to the node running on server, creating the private NIC and the NetworkInterface if needed
+func (r *PrivateNetworkReconciler) reconcileNode(ctx context.Context, log logr.Logger, pn *vpcv1alpha1.PrivateNetwork, apis *APIs, node *corev1.Node, server *instance.Server, prefix *goipam.Prefix) error {
+	lock, _ := r.nodeLocks.LoadOrStore(node.Name, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := r.Client.List(ctx, nicsList,
+		client.MatchingLabels{
+			constants.PrivateNetworkLabel: pn.Name,
+			constants.NodeLabel:           node.Name,
+		},
+	)
+	if err != nil {
+		log.Error(err, fmt.Sprintf("could not list NetworkInterface for node %s and privateNetwork %s", node.Name, pn.Name))
+		return err
+	}
+
+	if len(nicsList.Items) > 1 {
+		err := fmt.Errorf("node %s have %d networkInterfaces instead of at most one", node.Name, len(nicsList.Items))
+		log.Error(err, "could not handle node")
+		return err
+	}
+
+	privateNIC := findPrivateNIC(server, pn.PrivateNetworkID())
+	if privateNIC == nil {
+		// the server may have been looked up before the other pass created the private NIC, the cache entry is dropped then
+		server, err = getServer(log, apis, node)
+		if err != nil {
+			return err
 		}
-		if privateNIC == nil {
-			pnicResp, err := r.InstanceAPI.CreatePrivateNIC(&instance.CreatePrivateNICRequest{
-				Zone:             server.Zone,
-				PrivateNetworkID: pn.Spec.ID,
-				ServerID:         server.ID,
-			})
-			if err != nil {
-				log.Error(err, fmt.Sprintf("unable to create private on server %s", server.ID))
-				return ctrl.Result{RequeueAfter: RequeueDuration}, err
-			}
-			privateNIC = pnicResp.PrivateNic
+		privateNIC = findPrivateNIC(server, pn.PrivateNetworkID())
+	}
+	if privateNIC == nil {
+		log.Info(fmt.Sprintf("creating private nic on server %s", server.ID))
+		// the SDK doesn't set the tags of the private NICs yet
+		pnic, err := scwapi.CreatePrivateNIC(apis.Client, server.Zone, server.ID, pn.PrivateNetworkID(), r.privateNICTags(pn))
+		if err != nil {
+			r.recordPrivateNICCreationFailed(pn, node, server, err)
+			log.Error(err, fmt.Sprintf("unable to create private on server %s", server.ID))
+			return fmt.Errorf("could not create private nic on server %s: %w", server.ID, err)
+		}
+		apis.Servers.Invalidate(node)
+		privateNIC = &instance.PrivateNIC{
+			ID:               pnic.ID,
+			ServerID:         pnic.ServerID,
+			PrivateNetworkID: pnic.PrivateNetworkID,
+			MacAddress:       pnic.MacAddress,
+		}
+		r.recordPrivateNICCreated(pn, node, server, privateNIC)
+	}
+
+	if len(nicsList.Items) != 0 {
+		return nil
+	}
+	err = r.Reader.List(ctx, nicsList,
+		client.MatchingLabels{
+			constants.PrivateNetworkLabel: pn.Name,
+			constants.NodeLabel:           node.Name,
+		},
+	)
+	if err != nil {
+		log.Error(err, fmt.Sprintf("could not list NetworkInterface for node %s and privateNetwork %s", node.Name, pn.Name))
+		return err
+	}
+	if len(nicsList.Items) != 0 {
+		return nil
+	}
+
+	nic, err := r.constructNetworkInterfaceForPrivateNetwork(pn, node.Name)
+	if err != nil {
+		log.Error(err, "unable to construct networkInterface from privateNetwork")
+		return err
+	}
+
+	if prefix != nil {
+		ip, err := r.IPAM.AcquireIP(prefix.Cidr)
+		if err != nil {
+			ipamAllocationFailures.WithLabelValues(pn.Name).Inc()
+			r.Recorder.Event(pn, corev1.EventTypeWarning, constants.ReasonIPAMExhausted, fmt.Sprintf("Could not acquire IP in %s: %s", prefix.Cidr, err))
+			log.Error(err, fmt.Sprintf("error acquiring ip for cidr %s", prefix.Cidr))
+			return err
 		}
 
-		if len(nicsList.Items) > 1 {
-			log.Error(fmt.Errorf("node %s have %d networkInterfaces instead of at most one", node.Name, len(nicsList.Items)), "could not handle node")
-			return ctrl.Result{RequeueAfter: RequeueDuration}, err
+		// TODO have a better idea :D
+		nic.Spec.Address = ip.IP.String() + "/" + strings.Split(prefix.Cidr, "/")[1]
+	}
+
+	nic.Spec.ID = privateNIC.ID
+	err = r.Client.Create(ctx, nic)
+	if err != nil {
+		log.Error(err, "could not create networkInterface")
+		return err
+	}
+	patch := client.MergeFrom(nic.DeepCopy())
+	nic.Status.MacAddress = privateNIC.MacAddress
+	err = r.Client.Status().Patch(ctx, nic, patch)
+	if err != nil {
+		log.Error(err, "could not patch networkInterface status")
+		return err
+	}
+	log.Info(fmt.Sprintf("Successfully created networkInterface %s on node %s", nic.Name, node.Name))
+	return nil
+}
+
+// findPrivateNIC returns the private NIC of the server in the private network, nil if not attached
+func findPrivateNIC(server *instance.Server, privateNetworkID string) *instance.PrivateNIC {
+	for _, pnic := range server.PrivateNics {
+		if pnic.PrivateNetworkID == privateNetworkID {
+			return pnic
 		}
+	}
+	return nil
+}
 
-		if len(nicsList.Items) == 0 {
-			nic, err := r.constructNetworkInterfaceForPrivateNetwork(pn, node.Name)
-			if err != nil {
-				log.Error(err, "unable to construct networkInterface from privateNetwork")
-				return ctrl.Result{RequeueAfter: RequeueDuration}, err
-			}
-			ip, err := r.IPAM.AcquireIP(prefix.Cidr)
-			if err != nil {
-				log.Error(err, fmt.Sprintf("error acquiring ip for cidr %s", prefix.Cidr))
-				return ctrl.Result{RequeueAfter: RequeueDuration}, err
-			}
+// subnetCIDR returns the IPv4 subnet of the private network used by the Subnet IPAM,
+// checking the available ranges of the PrivateNetwork are within it
+func subnetCIDR(pn *vpcv1alpha1.PrivateNetwork, subnets []string) (string, error) {
+	var subnet *net.IPNet
+	for _, s := range subnets {
+		ip, ipNet, err := net.ParseCIDR(s)
+		if err == nil && ip.To4() != nil {
+			subnet = ipNet
+			break
+		}
+	}
+	if subnet == nil {
+		return "", fmt.Errorf("private network %s has no IPv4 subnet", pn.PrivateNetworkID())
+	}
 
-			// TODO have a better idea :D
-			nic.Spec.Address = ip.IP.String() + "/" + strings.Split(prefix.Cidr, "/")[1]
-			nic.Spec.ID = privateNIC.ID
-			err = r.Client.Create(ctx, nic)
+	if pn.Spec.IPAM.Static != nil {
+		subnetOnes, _ := subnet.Mask.Size()
+		for _, r := range pn.Spec.IPAM.Static.AvailableRanges {
+			ip, ipNet, err := net.ParseCIDR(r)
 			if err != nil {
-				log.Error(err, "could not create networkInterface")
-				return ctrl.Result{RequeueAfter: RequeueDuration}, err
+				return "", fmt.Errorf("invalid available range %s: %w", r, err)
 			}
-			patch := client.MergeFrom(nic.DeepCopy())
-			nic.Status.MacAddress = privateNIC.MacAddress
-			err = r.Client.Status().Patch(ctx, nic, patch)
-			if err != nil {
-				log.Error(err, "could not patch networkInterface status")
-				return ctrl.Result{RequeueAfter: RequeueDuration}, err
+			ones, _ := ipNet.Mask.Size()
+			if !subnet.Contains(ip) || ones < subnetOnes {
+				return "", fmt.Errorf("available range %s is not within the subnet %s", r, subnet)
 			}
-			log.Info(fmt.Sprintf("Successfully created networkInterface %s on node %s", nic.Name, node.Name))
 		}
 	}
+	return subnet.String(), nil
+}
+
+func (r *PrivateNetworkReconciler) recordPrivateNICCreated(pn *vpcv1alpha1.PrivateNetwork, node *corev1.Node, server *instance.Server, privateNIC *instance.PrivateNIC) {
+	msg := fmt.Sprintf("Created private NIC %s on server %s for private network %s", privateNIC.ID, server.ID, pn.PrivateNetworkID())
+	r.Recorder.Event(pn, corev1.EventTypeNormal, constants.ReasonPrivateNICCreated, msg)
+	r.Recorder.Event(node, corev1.EventTypeNormal, constants.ReasonPrivateNICCreated, msg)
+}
 
-	return ctrl.Result{}, nil
+func (r *PrivateNetworkReconciler) recordPrivateNICCreationFailed(pn *vpcv1alpha1.PrivateNetwork, node *corev1.Node, server *instance.Server, err error) {
+	msg := fmt.Sprintf("Could not create private NIC on server %s for private network %s: %s", server.ID, pn.PrivateNetworkID(), err)
+	r.Recorder.Event(pn, corev1.EventTypeWarning, constants.ReasonPrivateNICCreationFailed, msg)
+	r.Recorder.Event(node, corev1.EventTypeWarning, constants.ReasonPrivateNICCreationFailed, msg)
 }
 
 func (r *PrivateNetworkReconciler) constructNetworkInterfaceForPrivateNetwork(pn *vpcv1alpha1.PrivateNetwork, nodeName string) (*vpcv1alpha1.NetworkInterface, error) {
@@ -427,32 +609,17 @@ func (r *PrivateNetworkReconciler) constructNetworkInterfaceForPrivateNetwork(pn
 	}
 	controllerutil.AddFinalizer(nic, constants.FinalizerName)
 	controllerutil.AddFinalizer(nic, constants.IPFinalizerName)
+	if pn.Spec.Detach != nil {
+		controllerutil.AddFinalizer(nic, constants.DetachFinalizerName)
+	}
 
 	return nic, nil
 }
 
-func (r *PrivateNetworkReconciler) SetupWithManager(mgr ctrl.Manager) error {
+func (r *PrivateNetworkReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
 	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(options).
 		For(&vpcv1alpha1.PrivateNetwork{}).
 		Owns(&vpcv1alpha1.NetworkInterface{}).
-		Watches(&source.Kind{
-			Type: &corev1.Node{},
-		}, &handler.Funcs{
-			CreateFunc: func(e event.CreateEvent, q workqueue.RateLimitingInterface) {
-				pnsList := &vpcv1alpha1.PrivateNetworkList{}
-				err := r.Client.List(context.Background(), pnsList)
-				if err != nil {
-					r.Log.Error(err, "unable to sync privatenetwork on node creation")
-					return
-				}
-				for _, pn := range pnsList.Items {
-					q.Add(reconcile.Request{
-						NamespacedName: types.NamespacedName{
-							Name: pn.Name,
-						},
-					})
-				}
-			},
-		}).
 		Complete(r)
 }
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"testing"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+func TestSubnetCIDR(t *testing.T) {
+	subnetIPAM := func(ranges ...string) *vpcv1alpha1.PrivateNetwork {
+		pn := &vpcv1alpha1.PrivateNetwork{
+			Spec: vpcv1alpha1.PrivateNetworkSpec{
+				ID:   "pn-id",
+				IPAM: &vpcv1alpha1.PrivateNetworkIPAM{Type: vpcv1alpha1.IPAMTypeSubnet},
+			},
+		}
+		if len(ranges) != 0 {
+			pn.Spec.IPAM.Static = &vpcv1alpha1.PrivateNetworkIPAMStatic{AvailableRanges: ranges}
+		}
+		return pn
+	}
+
+	tests := []struct {
+		name     string
+		pn       *vpcv1alpha1.PrivateNetwork
+		subnets  []string
+		expected string
+		err      bool
+	}{
+		{
+			name:     "single subnet",
+			pn:       subnetIPAM(),
+			subnets:  []string{"172.16.4.0/22"},
+			expected: "172.16.4.0/22",
+		},
+		{
+			name: "no subnet",
+			pn:   subnetIPAM(),
+			err:  true,
+		},
+		{
+			name:    "only IPv6",
+			pn:      subnetIPAM(),
+			subnets: []string{"fd5f:519c:6d46:2728::/64"},
+			err:     true,
+		},
+		{
+			name:     "IPv6 and IPv4",
+			pn:       subnetIPAM(),
+			subnets:  []string{"fd5f:519c:6d46:2728::/64", "172.16.4.0/22"},
+			expected: "172.16.4.0/22",
+		},
+		{
+			name:     "several IPv4 subnets",
+			pn:       subnetIPAM(),
+			subnets:  []string{"172.16.4.0/22", "172.16.8.0/22"},
+			expected: "172.16.4.0/22",
+		},
+		{
+			name:     "invalid subnet skipped",
+			pn:       subnetIPAM(),
+			subnets:  []string{"invalid", "172.16.4.0/22"},
+			expected: "172.16.4.0/22",
+		},
+		{
+			name:     "subnet address normalized",
+			pn:       subnetIPAM(),
+			subnets:  []string{"172.16.4.1/22"},
+			expected: "172.16.4.0/22",
+		},
+		{
+			name:     "ranges within the subnet",
+			pn:       subnetIPAM("172.16.4.0/24", "172.16.6.0/23"),
+			subnets:  []string{"172.16.4.0/22"},
+			expected: "172.16.4.0/22",
+		},
+		{
+			name:     "range equal to the subnet",
+			pn:       subnetIPAM("172.16.4.0/22"),
+			subnets:  []string{"172.16.4.0/22"},
+			expected: "172.16.4.0/22",
+		},
+		{
+			name:    "range outside of the subnet",
+			pn:      subnetIPAM("10.0.0.0/24"),
+			subnets: []string{"172.16.4.0/22"},
+			err:     true,
+		},
+		{
+			name:    "range larger than the subnet",
+			pn:      subnetIPAM("172.16.0.0/16"),
+			subnets: []string{"172.16.4.0/22"},
+			err:     true,
+		},
+		{
+			name:    "range in the second subnet",
+			pn:      subnetIPAM("172.16.8.0/24"),
+			subnets: []string{"172.16.4.0/22", "172.16.8.0/22"},
+			err:     true,
+		},
+		{
+			name:    "invalid range",
+			pn:      subnetIPAM("172.16.4.0"),
+			subnets: []string{"172.16.4.0/22"},
+			err:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cidr, err := subnetCIDR(tt.pn, tt.subnets)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, got %s", cidr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if cidr != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, cidr)
+			}
+		})
+	}
+}
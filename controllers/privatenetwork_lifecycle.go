@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	vpc "github.com/scaleway/scaleway-sdk-go/api/vpc/v1"
+	"github.com/scaleway/scaleway-sdk-go/scw"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/scwapi"
+)
+
+// ownerTag returns the tag set on the private networks and the private NICs created for pn
+func ownerTag(pn *vpcv1alpha1.PrivateNetwork) string {
+	return constants.OwnerTagPrefix + string(pn.UID)
+}
+
+// managedTags returns the tags showing which cluster and PrivateNetwork own the private network, if enabled
+func (r *PrivateNetworkReconciler) managedTags(pn *vpcv1alpha1.PrivateNetwork) []string {
+	if !r.SyncTags {
+		return nil
+	}
+	tags := []string{constants.PrivateNetworkTagPrefix + pn.Name}
+	if r.ClusterName != "" {
+		tags = append(tags, constants.ClusterTagPrefix+r.ClusterName)
+	}
+	return tags
+}
+
+// privateNICTags returns the tags of the private NICs created for pn, the owner tag, so that the garbage collection only
+// deletes the private NICs created by the controller, and the managed tags
+func (r *PrivateNetworkReconciler) privateNICTags(pn *vpcv1alpha1.PrivateNetwork) []string {
+	return append([]string{ownerTag(pn)}, r.managedTags(pn)...)
+}
+
+func isManagedTag(tag string) bool {
+	return strings.HasPrefix(tag, constants.PrivateNetworkTagPrefix) || strings.HasPrefix(tag, constants.ClusterTagPrefix)
+}
+
+// hasTag returns whether tags contain tag
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// sameTags returns whether a and b contain the same tags, in any order
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := make(map[string]int, len(a))
+	for _, tag := range a {
+		count[tag]++
+	}
+	for _, tag := range b {
+		if count[tag] == 0 {
+			return false
+		}
+		count[tag]--
+	}
+	return true
+}
+
+// syncTags sets the managed tags on the private network, replacing the outdated ones and keeping the others
+func (r *PrivateNetworkReconciler) syncTags(log logr.Logger, pn *vpcv1alpha1.PrivateNetwork, apis *APIs, privateNetwork *vpc.PrivateNetwork) (*vpc.PrivateNetwork, error) {
+	managed := r.managedTags(pn)
+	if len(managed) == 0 {
+		return privateNetwork, nil
+	}
+
+	tags := []string{}
+	for _, tag := range privateNetwork.Tags {
+		if !isManagedTag(tag) {
+			tags = append(tags, tag)
+		}
+	}
+	tags = append(tags, managed...)
+	if sameTags(tags, privateNetwork.Tags) {
+		return privateNetwork, nil
+	}
+
+	log.Info(fmt.Sprintf("updating tags of private network %s", privateNetwork.ID))
+	return apis.VpcAPI.UpdatePrivateNetwork(&vpc.UpdatePrivateNetworkRequest{
+		Zone:             privateNetwork.Zone,
+		PrivateNetworkID: privateNetwork.ID,
+		Tags:             &tags,
+	})
+}
+
+// ensurePrivateNetwork returns the Scaleway private network of pn, creating it if no ID is given
+// The ID, name, tags, subnets and creation date of the private network are recorded in the status of pn
+func (r *PrivateNetworkReconciler) ensurePrivateNetwork(ctx context.Context, log logr.Logger, pn *vpcv1alpha1.PrivateNetwork, apis *APIs) (*vpc.PrivateNetwork, error) {
+	var privateNetwork *vpc.PrivateNetwork
+	if id := pn.PrivateNetworkID(); id != "" {
+		var err error
+		privateNetwork, err = apis.VpcAPI.GetPrivateNetwork(&vpc.GetPrivateNetworkRequest{
+			Zone:             scw.Zone(pn.Spec.Zone),
+			PrivateNetworkID: id,
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// the private network may have been created by a previous reconcile which failed to record its ID
+		tag := ownerTag(pn)
+		listResp, err := apis.VpcAPI.ListPrivateNetworks(&vpc.ListPrivateNetworksRequest{
+			Zone: scw.Zone(pn.Spec.Zone),
+			Tags: []string{tag},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(listResp.PrivateNetworks) > 1 {
+			return nil, fmt.Errorf("found %d private networks tagged %s instead of at most one", len(listResp.PrivateNetworks), tag)
+		}
+		if len(listResp.PrivateNetworks) == 1 {
+			privateNetwork = listResp.PrivateNetworks[0]
+		} else {
+			name := pn.Spec.Name
+			if name == "" {
+				name = pn.Name
+			}
+			tags := append(append([]string{}, pn.Spec.Tags...), tag)
+			tags = append(tags, r.managedTags(pn)...)
+			privateNetwork, err = apis.VpcAPI.CreatePrivateNetwork(&vpc.CreatePrivateNetworkRequest{
+				Zone: scw.Zone(pn.Spec.Zone),
+				Name: name,
+				Tags: tags,
+			})
+			if err != nil {
+				return nil, err
+			}
+			log.Info(fmt.Sprintf("created private network %s", privateNetwork.ID))
+			r.Recorder.Event(pn, corev1.EventTypeNormal, constants.ReasonPrivateNetworkCreated, fmt.Sprintf("Created private network %s", privateNetwork.ID))
+		}
+	}
+
+	privateNetwork, err := r.syncTags(log, pn, apis, privateNetwork)
+	if err != nil {
+		return nil, err
+	}
+	subnets, err := scwapi.PrivateNetworkSubnets(apis.Client, privateNetwork.Zone, privateNetwork.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.updateStatus(ctx, pn, func(status *vpcv1alpha1.PrivateNetworkStatus) {
+		status.ID = privateNetwork.ID
+		status.Name = privateNetwork.Name
+		status.Tags = privateNetwork.Tags
+		status.Subnets = subnets
+		status.CreatedAt = nil
+		if privateNetwork.CreatedAt != nil {
+			// the status is stored with a second precision
+			createdAt := metav1.NewTime(privateNetwork.CreatedAt.Truncate(time.Second))
+			status.CreatedAt = &createdAt
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return privateNetwork, nil
+}
+
+// deletePrivateNetwork deletes the Scaleway private network of pn if its deletion policy is Delete
+// Only the private network created by the controller for pn, with its owner tag, is deleted, a private network
+// given with spec.id is always kept
+func (r *PrivateNetworkReconciler) deletePrivateNetwork(ctx context.Context, log logr.Logger, pn *vpcv1alpha1.PrivateNetwork) error {
+	id := pn.PrivateNetworkID()
+	if pn.Spec.DeletionPolicy != vpcv1alpha1.DeletionPolicyDelete || id == "" {
+		return nil
+	}
+
+	apis, err := r.APIs.ForPrivateNetwork(ctx, pn)
+	if err != nil {
+		return err
+	}
+	privateNetwork, err := apis.VpcAPI.GetPrivateNetwork(&vpc.GetPrivateNetworkRequest{
+		Zone:             scw.Zone(pn.Spec.Zone),
+		PrivateNetworkID: id,
+	})
+	if err != nil {
+		if scwapi.Classify(err) == scwapi.ErrorClassNotFound {
+			return nil
+		}
+		r.Recorder.Event(pn, corev1.EventTypeWarning, constants.ReasonPrivateNetworkDeletionFailed, fmt.Sprintf("Could not get private network %s: %s", id, err))
+		return err
+	}
+	if !hasTag(privateNetwork.Tags, ownerTag(pn)) {
+		log.Info(fmt.Sprintf("keeping private network %s not created by the controller", id))
+		r.Recorder.Event(pn, corev1.EventTypeNormal, constants.ReasonPrivateNetworkRetained, fmt.Sprintf("Kept private network %s not created for this PrivateNetwork", id))
+		return nil
+	}
+
+	err = apis.VpcAPI.DeletePrivateNetwork(&vpc.DeletePrivateNetworkRequest{
+		Zone:             scw.Zone(pn.Spec.Zone),
+		PrivateNetworkID: id,
+	})
+	if err != nil {
+		if scwapi.Classify(err) == scwapi.ErrorClassNotFound {
+			return nil
+		}
+		r.Recorder.Event(pn, corev1.EventTypeWarning, constants.ReasonPrivateNetworkDeletionFailed, fmt.Sprintf("Could not delete private network %s: %s", id, err))
+		return err
+	}
+	log.Info(fmt.Sprintf("deleted private network %s", id))
+	r.Recorder.Event(pn, corev1.EventTypeNormal, constants.ReasonPrivateNetworkDeleted, fmt.Sprintf("Deleted private network %s", id))
+	return nil
+}
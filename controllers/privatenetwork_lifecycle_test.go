@@ -0,0 +1,343 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+)
+
+const privateNetworksPath = "/vpc/v1/zones/fr-par-1/private-networks"
+
+type fakePrivateNetwork struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Zone    string   `json:"zone"`
+	Tags    []string `json:"tags"`
+	Subnets []string `json:"subnets"`
+}
+
+// fakePrivateNetworks is a fake of the private networks endpoints of the VPC API
+type fakePrivateNetworks struct {
+	lock            sync.Mutex
+	privateNetworks map[string]*fakePrivateNetwork
+	created         []string
+	deleted         []string
+}
+
+func (f *fakePrivateNetworks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, privateNetworksPath), "/")
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		pns := []*fakePrivateNetwork{}
+		for _, pn := range f.privateNetworks {
+			matching := true
+			for _, tag := range r.URL.Query()["tags"] {
+				matching = matching && hasTag(pn.Tags, tag)
+			}
+			if matching {
+				pns = append(pns, pn)
+			}
+		}
+		sort.Slice(pns, func(i, j int) bool { return pns[i].ID < pns[j].ID })
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"private_networks": pns, "total_count": len(pns)})
+	case r.Method == http.MethodPost && id == "":
+		pn := &fakePrivateNetwork{}
+		if err := json.NewDecoder(r.Body).Decode(pn); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		pn.ID = fmt.Sprintf("created-%d", len(f.created)+1)
+		pn.Zone = "fr-par-1"
+		f.privateNetworks[pn.ID] = pn
+		f.created = append(f.created, pn.ID)
+		_ = json.NewEncoder(w).Encode(pn)
+	case f.privateNetworks[id] == nil:
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]string{"message": "private network not found"})
+	case r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(f.privateNetworks[id])
+	case r.Method == http.MethodPatch:
+		update := struct {
+			Tags *[]string `json:"tags"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if update.Tags != nil {
+			f.privateNetworks[id].Tags = *update.Tags
+		}
+		_ = json.NewEncoder(w).Encode(f.privateNetworks[id])
+	case r.Method == http.MethodDelete:
+		delete(f.privateNetworks, id)
+		f.deleted = append(f.deleted, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func lifecyclePrivateNetwork(id string) *vpcv1alpha1.PrivateNetwork {
+	pn := reconciledPrivateNetwork("pn", "")
+	pn.Spec.ID = id
+	pn.Spec.Zone = "fr-par-1"
+	pn.Status.ID = ""
+	return pn
+}
+
+func newLifecycleReconciler(t *testing.T, privateNetworks *fakePrivateNetworks, pn *vpcv1alpha1.PrivateNetwork) (*PrivateNetworkReconciler, *record.FakeRecorder) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = vpcv1alpha1.AddToScheme(scheme)
+
+	recorder := record.NewFakeRecorder(10)
+	return &PrivateNetworkReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme, pn),
+		Log:      ctrl.Log.WithName("test"),
+		Scheme:   scheme,
+		APIs:     newTestAPIsCache(t, privateNetworks),
+		Recorder: recorder,
+	}, recorder
+}
+
+// events returns the reasons of the recorded events
+func events(recorder *record.FakeRecorder) []string {
+	reasons := []string{}
+	for {
+		select {
+		case e := <-recorder.Events:
+			reasons = append(reasons, strings.Fields(e)[1])
+		default:
+			return reasons
+		}
+	}
+}
+
+func TestEnsurePrivateNetwork(t *testing.T) {
+	owner := constants.OwnerTagPrefix + "pn-uid"
+
+	tests := []struct {
+		name     string
+		id       string
+		specName string
+		syncTags bool
+		existing []*fakePrivateNetwork
+		expected *fakePrivateNetwork
+		created  []string
+		events   []string
+		err      bool
+	}{
+		{
+			name:     "created",
+			specName: "my-network",
+			expected: &fakePrivateNetwork{ID: "created-1", Name: "my-network", Tags: []string{"my-cluster", owner}},
+			created:  []string{"created-1"},
+			events:   []string{constants.ReasonPrivateNetworkCreated},
+		},
+		{
+			name:     "created with the name of the object and the managed tags",
+			syncTags: true,
+			expected: &fakePrivateNetwork{ID: "created-1", Name: "pn", Tags: []string{"my-cluster", owner, constants.PrivateNetworkTagPrefix + "pn"}},
+			created:  []string{"created-1"},
+			events:   []string{constants.ReasonPrivateNetworkCreated},
+		},
+		{
+			// a previous reconcile created it, and failed to record its ID
+			name: "already created",
+			existing: []*fakePrivateNetwork{
+				{ID: "other", Name: "other", Tags: []string{constants.OwnerTagPrefix + "other-uid"}},
+				{ID: "tagged", Name: "pn", Tags: []string{owner}, Subnets: []string{"172.16.4.0/22"}},
+			},
+			expected: &fakePrivateNetwork{ID: "tagged", Name: "pn", Tags: []string{owner}, Subnets: []string{"172.16.4.0/22"}},
+		},
+		{
+			name: "created twice",
+			existing: []*fakePrivateNetwork{
+				{ID: "tagged-1", Name: "pn", Tags: []string{owner}},
+				{ID: "tagged-2", Name: "pn", Tags: []string{owner}},
+			},
+			err: true,
+		},
+		{
+			name:     "given id",
+			id:       "existing",
+			existing: []*fakePrivateNetwork{{ID: "existing", Name: "existing", Tags: []string{"user"}, Subnets: []string{"172.16.4.0/22"}}},
+			expected: &fakePrivateNetwork{ID: "existing", Name: "existing", Tags: []string{"user"}, Subnets: []string{"172.16.4.0/22"}},
+		},
+		{
+			name:     "given id with the managed tags",
+			id:       "existing",
+			syncTags: true,
+			existing: []*fakePrivateNetwork{{ID: "existing", Name: "existing", Tags: []string{"user", constants.PrivateNetworkTagPrefix + "old"}}},
+			expected: &fakePrivateNetwork{ID: "existing", Name: "existing", Tags: []string{"user", constants.PrivateNetworkTagPrefix + "pn"}},
+		},
+		{
+			name: "given id not found",
+			id:   "missing",
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakePrivateNetworks{privateNetworks: map[string]*fakePrivateNetwork{}}
+			for _, pn := range tt.existing {
+				pn.Zone = "fr-par-1"
+				fake.privateNetworks[pn.ID] = pn
+			}
+			pn := lifecyclePrivateNetwork(tt.id)
+			pn.Spec.Name = tt.specName
+			pn.Spec.Tags = []string{"my-cluster"}
+			r, recorder := newLifecycleReconciler(t, fake, pn)
+			r.SyncTags = tt.syncTags
+			apis, err := r.APIs.Default()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			privateNetwork, err := r.ensurePrivateNetwork(context.Background(), r.Log, pn, apis)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, got private network %s", privateNetwork.ID)
+				}
+				if len(fake.created) != 0 {
+					t.Errorf("expected no private network to be created, got %v", fake.created)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if privateNetwork.ID != tt.expected.ID || privateNetwork.Name != tt.expected.Name || !sameTags(privateNetwork.Tags, tt.expected.Tags) {
+				t.Errorf("expected private network %v, got %s %s %v", tt.expected, privateNetwork.ID, privateNetwork.Name, privateNetwork.Tags)
+			}
+			if stored := fake.privateNetworks[tt.expected.ID]; !sameTags(stored.Tags, tt.expected.Tags) {
+				t.Errorf("expected tags %v at Scaleway, got %v", tt.expected.Tags, stored.Tags)
+			}
+			if !reflect.DeepEqual(fake.created, tt.created) && len(fake.created)+len(tt.created) != 0 {
+				t.Errorf("expected created private networks %v, got %v", tt.created, fake.created)
+			}
+			if got := events(recorder); !reflect.DeepEqual(got, tt.events) && len(got)+len(tt.events) != 0 {
+				t.Errorf("expected events %v, got %v", tt.events, got)
+			}
+
+			// the private network is recorded in the status
+			stored := &vpcv1alpha1.PrivateNetwork{}
+			err = r.Client.Get(context.Background(), types.NamespacedName{Name: pn.Name}, stored)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if stored.Status.ID != tt.expected.ID || stored.Status.Name != tt.expected.Name ||
+				!sameTags(stored.Status.Tags, tt.expected.Tags) || !reflect.DeepEqual(stored.Status.Subnets, tt.expected.Subnets) {
+				t.Errorf("expected status of private network %v, got %v", tt.expected, stored.Status)
+			}
+		})
+	}
+}
+
+func TestDeletePrivateNetwork(t *testing.T) {
+	owner := constants.OwnerTagPrefix + "pn-uid"
+
+	tests := []struct {
+		name     string
+		id       string
+		statusID string
+		policy   vpcv1alpha1.DeletionPolicy
+		existing *fakePrivateNetwork
+		deleted  []string
+		events   []string
+	}{
+		{
+			name:     "retained",
+			statusID: "created-1",
+			policy:   vpcv1alpha1.DeletionPolicyRetain,
+			existing: &fakePrivateNetwork{ID: "created-1", Tags: []string{owner}},
+		},
+		{
+			name:     "created by the controller",
+			statusID: "created-1",
+			policy:   vpcv1alpha1.DeletionPolicyDelete,
+			existing: &fakePrivateNetwork{ID: "created-1", Tags: []string{owner}},
+			deleted:  []string{"created-1"},
+			events:   []string{constants.ReasonPrivateNetworkDeleted},
+		},
+		{
+			name:     "given id",
+			id:       "existing",
+			policy:   vpcv1alpha1.DeletionPolicyDelete,
+			existing: &fakePrivateNetwork{ID: "existing", Tags: []string{"user"}},
+			events:   []string{constants.ReasonPrivateNetworkRetained},
+		},
+		{
+			name:     "given id of a private network created for another PrivateNetwork",
+			id:       "existing",
+			policy:   vpcv1alpha1.DeletionPolicyDelete,
+			existing: &fakePrivateNetwork{ID: "existing", Tags: []string{constants.OwnerTagPrefix + "other-uid"}},
+			events:   []string{constants.ReasonPrivateNetworkRetained},
+		},
+		{
+			// the PrivateNetwork was recreated with the ID of the private network created for the previous one
+			name:     "given id of a private network created for this PrivateNetwork",
+			id:       "created-1",
+			policy:   vpcv1alpha1.DeletionPolicyDelete,
+			existing: &fakePrivateNetwork{ID: "created-1", Tags: []string{owner}},
+			deleted:  []string{"created-1"},
+			events:   []string{constants.ReasonPrivateNetworkDeleted},
+		},
+		{
+			name:     "already deleted",
+			statusID: "created-1",
+			policy:   vpcv1alpha1.DeletionPolicyDelete,
+		},
+		{
+			name:   "never created",
+			policy: vpcv1alpha1.DeletionPolicyDelete,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakePrivateNetworks{privateNetworks: map[string]*fakePrivateNetwork{}}
+			if tt.existing != nil {
+				tt.existing.Zone = "fr-par-1"
+				fake.privateNetworks[tt.existing.ID] = tt.existing
+			}
+			pn := lifecyclePrivateNetwork(tt.id)
+			pn.Status.ID = tt.statusID
+			pn.Spec.DeletionPolicy = tt.policy
+			r, recorder := newLifecycleReconciler(t, fake, pn)
+
+			err := r.deletePrivateNetwork(context.Background(), r.Log, pn)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(fake.deleted, tt.deleted) && len(fake.deleted)+len(tt.deleted) != 0 {
+				t.Errorf("expected deleted private networks %v, got %v", tt.deleted, fake.deleted)
+			}
+			if got := events(recorder); !reflect.DeepEqual(got, tt.events) && len(got)+len(tt.events) != 0 {
+				t.Errorf("expected events %v, got %v", tt.events, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DefaultServerCacheTTL is the default duration a Scaleway server is cached
+const DefaultServerCacheTTL = time.Minute * 5
+
+type cachedServer struct {
+	server    *instance.Server
+	expiresAt time.Time
+}
+
+// ServerCache caches the Scaleway servers of the nodes, so that all the PrivateNetworks share the same lookup
+type ServerCache struct {
+	InstanceAPI *instance.API
+	TTL         time.Duration
+
+	lock    sync.Mutex
+	servers map[string]cachedServer
+}
+
+// NewServerCache returns an empty ServerCache
+func NewServerCache(instanceAPI *instance.API, ttl time.Duration) *ServerCache {
+	return &ServerCache{
+		InstanceAPI: instanceAPI,
+		TTL:         ttl,
+		servers:     make(map[string]cachedServer),
+	}
+}
+
+// serverCacheKey returns the provider ID of the node, or its name if the provider ID is not set
+func serverCacheKey(node *corev1.Node) string {
+	if node.Spec.ProviderID != "" {
+		return node.Spec.ProviderID
+	}
+	return "name://" + node.Name
+}
+
+// Get returns the server of the node, from the cache if not expired
+func (c *ServerCache) Get(node *corev1.Node) (*instance.Server, error) {
+	key := serverCacheKey(node)
+
+	c.lock.Lock()
+	cached, ok := c.servers[key]
+	c.lock.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.server, nil
+	}
+
+	server, err := getServerFromNode(c.InstanceAPI, node)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.servers[key] = cachedServer{
+		server:    server,
+		expiresAt: time.Now().Add(c.TTL),
+	}
+	return server, nil
+}
+
+// Invalidate drops the server of the node from the cache, it must be called after any change made to the server
+func (c *ServerCache) Invalidate(node *corev1.Node) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.servers, serverCacheKey(node))
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scaleway/scaleway-sdk-go/scw"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/scwapi"
+)
+
+const serversPath = "/instance/v1/zones/fr-par-1/servers"
+
+// fakeServers is a fake of the servers and private NICs endpoints of the instance API
+type fakeServers struct {
+	lock sync.Mutex
+	// pnics are the private NICs per server ID
+	pnics   map[string][]*scwapi.PrivateNIC
+	gets    int
+	created []*scwapi.PrivateNIC
+	deleted []string
+}
+
+func (f *fakeServers) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, serversPath), "/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == serversPath:
+		servers := []map[string]string{}
+		for id, pnics := range f.pnics {
+			for _, pnic := range pnics {
+				if pnic.PrivateNetworkID == r.URL.Query().Get("private_network") {
+					servers = append(servers, map[string]string{"id": id, "zone": "fr-par-1"})
+					break
+				}
+			}
+		}
+		sort.Slice(servers, func(i, j int) bool { return servers[i]["id"] < servers[j]["id"] })
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"servers": servers, "total_count": len(servers)})
+	case r.Method == http.MethodGet && len(parts) == 2:
+		pnics, ok := f.pnics[parts[1]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]string{"message": "server not found"})
+			return
+		}
+		f.gets++
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"server": map[string]interface{}{"id": parts[1], "zone": "fr-par-1", "private_nics": pnics},
+		})
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[2] == "private_nics":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"private_nics": f.pnics[parts[1]]})
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[2] == "private_nics":
+		pnic := &scwapi.PrivateNIC{}
+		if err := json.NewDecoder(r.Body).Decode(pnic); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		pnic.ID = fmt.Sprintf("created-%d", len(f.created)+1)
+		pnic.ServerID = parts[1]
+		pnic.MacAddress = fmt.Sprintf("02:00:00:00:00:%02d", len(f.created)+1)
+		f.pnics[parts[1]] = append(f.pnics[parts[1]], pnic)
+		f.created = append(f.created, pnic)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"private_nic": pnic})
+	case r.Method == http.MethodDelete && len(parts) == 4 && parts[2] == "private_nics":
+		pnics := []*scwapi.PrivateNIC{}
+		for _, pnic := range f.pnics[parts[1]] {
+			if pnic.ID != parts[3] {
+				pnics = append(pnics, pnic)
+			}
+		}
+		f.pnics[parts[1]] = pnics
+		f.deleted = append(f.deleted, parts[3])
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// newTestAPIsCache returns an APIsCache whose default APIs send the requests to the fake
+func newTestAPIsCache(t *testing.T, fake http.Handler) *APIsCache {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client, err := scw.NewClient(
+		scw.WithoutAuth(),
+		scw.WithAPIURL(server.URL),
+		scw.WithDefaultZone(scw.ZoneFrPar1),
+	)
+	if err != nil {
+		t.Fatalf("could not create client: %s", err)
+	}
+	apisCache := NewAPIsCache(nil, "", time.Minute)
+	apisCache.SetDefault(NewAPIs(client, time.Minute))
+	return apisCache
+}
+
+func scalewayNode(name, serverID string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       corev1.NodeSpec{ProviderID: "scaleway://instance/fr-par-1/" + serverID},
+	}
+}
+
+func TestServerCache(t *testing.T) {
+	fake := &fakeServers{
+		pnics: map[string][]*scwapi.PrivateNIC{
+			"server-1": {{ID: "pnic", ServerID: "server-1", PrivateNetworkID: "pn-id"}},
+			"server-2": {},
+		},
+	}
+	apis, err := newTestAPIsCache(t, fake).Default()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cache := apis.Servers
+	node1 := scalewayNode("node-1", "server-1")
+	node2 := scalewayNode("node-2", "server-2")
+
+	get := func(node *corev1.Node, expectedGets int) {
+		t.Helper()
+		server, err := cache.Get(node)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if serverID, _ := serverIDFromNode(node); server.ID != serverID {
+			t.Errorf("expected server %s, got %s", serverID, server.ID)
+		}
+		if fake.gets != expectedGets {
+			t.Errorf("expected %d lookups, got %d", expectedGets, fake.gets)
+		}
+	}
+
+	get(node1, 1)
+	// cached
+	get(node1, 1)
+	// cached per node
+	get(node2, 2)
+
+	cache.Invalidate(node1)
+	get(node1, 3)
+	get(node2, 3)
+
+	// expired
+	cache.TTL = 0
+	cache.Invalidate(node2)
+	get(node2, 4)
+	get(node2, 5)
+
+	// the errors are not cached
+	cache.TTL = time.Minute
+	missing := scalewayNode("missing", "server-3")
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Get(missing); err == nil {
+			t.Errorf("expected an error for a missing server")
+		}
+	}
+	if fake.gets != 5 {
+		t.Errorf("expected the missing server not to be cached, got %d lookups", fake.gets)
+	}
+}
@@ -7,9 +7,89 @@ const (
 	// FinalizerName is the name of the finalizer
 	FinalizerName = "scaleway.com/finalizer"
 
+	// DetachFinalizerName is the name of the finalizer holding the detach of a NetworkInterface until the pods are gone
+	DetachFinalizerName = "scaleway.com/finalizer-detach"
+
 	// PrivateNetworkLabel is the private network label
 	PrivateNetworkLabel = "private-network"
 
 	// NodeLabel is the node label
 	NodeLabel = "node"
+
+	// OwnerTagPrefix prefixes the UID of the PrivateNetwork in the tags of the private networks created by the controller
+	OwnerTagPrefix = "scaleway-k8s-vpc-owner="
+
+	// ClusterTagPrefix prefixes the name of the cluster in the managed tags of the private networks
+	ClusterTagPrefix = "scaleway-k8s-vpc-cluster="
+
+	// PrivateNetworkTagPrefix prefixes the name of the PrivateNetwork in the managed tags of the private networks
+	PrivateNetworkTagPrefix = "scaleway-k8s-vpc-privatenetwork="
+)
+
+// Event reasons, used on the PrivateNetwork, NetworkInterface and Node objects
+const (
+	// ReasonPrivateNetworkCreated is used when a private network is created at Scaleway
+	ReasonPrivateNetworkCreated = "PrivateNetworkCreated"
+	// ReasonPrivateNetworkDeleted is used when a private network is deleted at Scaleway
+	ReasonPrivateNetworkDeleted = "PrivateNetworkDeleted"
+	// ReasonPrivateNetworkDeletionFailed is used when a private network could not be deleted at Scaleway
+	ReasonPrivateNetworkDeletionFailed = "PrivateNetworkDeletionFailed"
+	// ReasonPrivateNetworkRetained is used when a private network not created by the controller is kept despite the Delete policy
+	ReasonPrivateNetworkRetained = "PrivateNetworkRetained"
+	// ReasonPrivateNICCreated is used when a private NIC is created on a Scaleway server
+	ReasonPrivateNICCreated = "PrivateNICCreated"
+	// ReasonPrivateNICCreationFailed is used when a private NIC could not be created on a Scaleway server
+	ReasonPrivateNICCreationFailed = "PrivateNICCreationFailed"
+	// ReasonPrivateNICDeleted is used when a private NIC is deleted from a Scaleway server
+	ReasonPrivateNICDeleted = "PrivateNICDeleted"
+	// ReasonPrivateNICDeletionFailed is used when a private NIC could not be deleted from a Scaleway server
+	ReasonPrivateNICDeletionFailed = "PrivateNICDeletionFailed"
+	// ReasonIPAcquired is used when an IP is allocated to a NetworkInterface
+	ReasonIPAcquired = "IPAcquired"
+	// ReasonIPReleased is used when the IP of a NetworkInterface is released
+	ReasonIPReleased = "IPReleased"
+	// ReasonIPAdopted is used when the address found on the link of a NetworkInterface is imported into the IPAM
+	ReasonIPAdopted = "IPAdopted"
+	// ReasonAdoptionConflict is used when the address found on the link of a NetworkInterface could not be imported into the IPAM
+	ReasonAdoptionConflict = "AdoptionConflict"
+	// ReasonAddressReserved is used when the IP of a NetworkInterface is reserved in the DHCP server of the Public Gateway
+	ReasonAddressReserved = "AddressReserved"
+	// ReasonAddressReservationFailed is used when the IP of a NetworkInterface could not be reserved in the DHCP server of the Public Gateway
+	ReasonAddressReservationFailed = "AddressReservationFailed"
+	// ReasonGatewayAddressNotFound is used when the Public Gateway of a PrivateNetwork has no address in the private network
+	ReasonGatewayAddressNotFound = "GatewayAddressNotFound"
+	// ReasonIPAMExhausted is used when no IP is left in the ranges of a PrivateNetwork
+	ReasonIPAMExhausted = "IPAMExhausted"
+	// ReasonDetachWaiting is used when the detach of a NetworkInterface waits for the node to be drained
+	ReasonDetachWaiting = "DetachWaiting"
+	// ReasonOrphanFound is used when the garbage collection finds a private NIC or an IP without NetworkInterface
+	ReasonOrphanFound = "OrphanFound"
+	// ReasonOrphanCollected is used when the garbage collection deletes a private NIC or an IP without NetworkInterface
+	ReasonOrphanCollected = "OrphanCollected"
+	// ReasonLinkConfigured is used when the link of a NetworkInterface is configured on the node
+	ReasonLinkConfigured = "LinkConfigured"
+	// ReasonLinkConfigurationFailed is used when the link of a NetworkInterface could not be configured on the node
+	ReasonLinkConfigurationFailed = "LinkConfigurationFailed"
+	// ReasonRouteSyncFailed is used when the routes of a NetworkInterface could not be synced on the node
+	ReasonRouteSyncFailed = "RouteSyncFailed"
+)
+
+// Condition reasons, used on the PrivateNetwork conditions
+const (
+	// ReasonReconciled is used when the PrivateNetwork is reconciled
+	ReasonReconciled = "Reconciled"
+	// ReasonInvalidCredentials is used when the Scaleway credentials of the PrivateNetwork could not be loaded
+	ReasonInvalidCredentials = "InvalidCredentials"
+	// ReasonInvalidIPAM is used when the IPAM of the PrivateNetwork doesn't match the Scaleway private network
+	ReasonInvalidIPAM = "InvalidIPAM"
+	// ReasonNodesFailed is used when the PrivateNetwork could not be attached to some nodes
+	ReasonNodesFailed = "NodesFailed"
+	// ReasonAPITransientError is used when the Scaleway API returned an error expected to go away by itself
+	ReasonAPITransientError = "APITransientError"
+	// ReasonAPIQuotaExceeded is used when a Scaleway quota is exceeded or the requests are throttled
+	ReasonAPIQuotaExceeded = "APIQuotaExceeded"
+	// ReasonAPINotFound is used when the Scaleway API could not find a resource
+	ReasonAPINotFound = "APINotFound"
+	// ReasonAPIPermissionDenied is used when the Scaleway API denied the credentials or the permission
+	ReasonAPIPermissionDenied = "APIPermissionDenied"
 )
@@ -0,0 +1,59 @@
+package options
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
+)
+
+const (
+	// EnvPrefix is the prefix of the environment variables setting the flags
+	EnvPrefix = "SCALEWAY_K8S_VPC_"
+
+	// DefaultMinBackoff is the default delay before requeuing a failed reconcile, doubled on each failure
+	DefaultMinBackoff = time.Millisecond * 5
+	// DefaultMaxBackoff is the default longest delay before requeuing a failed reconcile
+	DefaultMaxBackoff = time.Second * 1000
+)
+
+// FromEnv sets the flags of fs not given on the command line from the environment
+// The variable of a flag is its name upper cased with dashes replaced by underscores, prefixed by EnvPrefix,
+// e.g. SCALEWAY_K8S_VPC_SYNC_PERIOD for the sync-period flag
+// It must be called after fs is parsed
+func FromEnv(fs *flag.FlagSet) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] {
+			return
+		}
+		name := EnvPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %w", value, name, setErr)
+		}
+	})
+	return err
+}
+
+// RateLimiter returns the default rate limiter of the controllers, with a per item exponential backoff between minBackoff and maxBackoff
+func RateLimiter(minBackoff, maxBackoff time.Duration) ratelimiter.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(minBackoff, maxBackoff),
+		// overall 10 qps with a burst of 100, as the workqueue default
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+	)
+}
@@ -0,0 +1,122 @@
+package options
+
+import (
+	"flag"
+	"os"
+	"testing"
+	"time"
+)
+
+func setenv(t *testing.T, name, value string) {
+	t.Helper()
+	if err := os.Setenv(name, value); err != nil {
+		t.Fatalf("could not set %s: %s", name, err)
+	}
+	t.Cleanup(func() {
+		os.Unsetenv(name)
+	})
+}
+
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		expected map[string]string
+		err      bool
+	}{
+		{
+			name:     "defaults",
+			expected: map[string]string{"sync-period": "10m0s", "leader-elect": "false", "zone": "fr-par-1"},
+		},
+		{
+			name: "from the environment",
+			env: map[string]string{
+				"SCALEWAY_K8S_VPC_SYNC_PERIOD":  "1m",
+				"SCALEWAY_K8S_VPC_LEADER_ELECT": "true",
+				// without the prefix
+				"ZONE": "nl-ams-1",
+			},
+			expected: map[string]string{"sync-period": "1m0s", "leader-elect": "true", "zone": "fr-par-1"},
+		},
+		{
+			name: "command line over the environment",
+			args: []string{"--sync-period=30s"},
+			env: map[string]string{
+				"SCALEWAY_K8S_VPC_SYNC_PERIOD": "1m",
+				"SCALEWAY_K8S_VPC_ZONE":        "nl-ams-1",
+			},
+			expected: map[string]string{"sync-period": "30s", "leader-elect": "false", "zone": "nl-ams-1"},
+		},
+		{
+			name: "command line set to the default",
+			args: []string{"--zone=fr-par-1"},
+			env: map[string]string{
+				"SCALEWAY_K8S_VPC_ZONE": "nl-ams-1",
+			},
+			expected: map[string]string{"sync-period": "10m0s", "leader-elect": "false", "zone": "fr-par-1"},
+		},
+		{
+			name: "invalid value",
+			env: map[string]string{
+				"SCALEWAY_K8S_VPC_SYNC_PERIOD": "often",
+			},
+			err: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				setenv(t, name, value)
+			}
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.Duration("sync-period", 10*time.Minute, "")
+			fs.Bool("leader-elect", false, "")
+			fs.String("zone", "fr-par-1", "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			err := FromEnv(fs)
+			if tt.err {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			for name, expected := range tt.expected {
+				if got := fs.Lookup(name).Value.String(); got != expected {
+					t.Errorf("expected %s for %s, got %s", expected, name, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := RateLimiter(time.Second, 4*time.Second)
+
+	// the delay of an item doubles on each failure, up to the max backoff
+	for i, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		if got := limiter.When("a"); got != expected {
+			t.Errorf("expected a delay of %s for failure %d, got %s", expected, i+1, got)
+		}
+	}
+	if got := limiter.NumRequeues("a"); got != 4 {
+		t.Errorf("expected 4 requeues, got %d", got)
+	}
+
+	// the backoff is per item
+	if got := limiter.When("b"); got != time.Second {
+		t.Errorf("expected a delay of 1s for another item, got %s", got)
+	}
+
+	limiter.Forget("a")
+	if got := limiter.When("a"); got != time.Second {
+		t.Errorf("expected a delay of 1s after forgetting the item, got %s", got)
+	}
+}
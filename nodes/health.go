@@ -0,0 +1,72 @@
+package nodes
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// ConfigurationTracker tracks since when the NetworkInterfaces of the node are not configured
+type ConfigurationTracker struct {
+	lock         sync.Mutex
+	unconfigured map[string]time.Time
+}
+
+// NewConfigurationTracker returns an empty ConfigurationTracker
+func NewConfigurationTracker() *ConfigurationTracker {
+	return &ConfigurationTracker{
+		unconfigured: make(map[string]time.Time),
+	}
+}
+
+// Unconfigured marks the NetworkInterface as not configured, if not already
+func (t *ConfigurationTracker) Unconfigured(name string) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if _, ok := t.unconfigured[name]; !ok {
+		t.unconfigured[name] = time.Now()
+	}
+}
+
+// Configured marks the NetworkInterface as configured
+func (t *ConfigurationTracker) Configured(name string) {
+	t.Forget(name)
+}
+
+// Forget stops tracking the NetworkInterface
+func (t *ConfigurationTracker) Forget(name string) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.unconfigured, name)
+}
+
+// Checker returns a checker failing when a NetworkInterface is not configured for more than timeout
+func (t *ConfigurationTracker) Checker(timeout time.Duration) healthz.Checker {
+	return func(_ *http.Request) error {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+
+		names := []string{}
+		for name, since := range t.unconfigured {
+			if time.Since(since) > timeout {
+				names = append(names, name)
+			}
+		}
+		if len(names) != 0 {
+			sort.Strings(names)
+			return fmt.Errorf("networkInterfaces %s not configured for more than %s", strings.Join(names, ", "), timeout)
+		}
+		return nil
+	}
+}
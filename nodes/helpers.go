@@ -0,0 +1,108 @@
+package nodes
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"text/template"
+
+	"github.com/vishvananda/netlink"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+type linkNameData struct {
+	Name string
+	ID   string
+}
+
+func linkNameFromTemplate(pnet *vpcv1alpha1.PrivateNetwork) (string, error) {
+	tmpl, err := template.New("linkName").Option("missingkey=error").Parse(pnet.Spec.LinkName)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, linkNameData{
+		Name: pnet.Name,
+		ID:   pnet.PrivateNetworkID(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// a truncated name could be the same for several private networks
+	name := buf.String()
+	if len(name) == 0 || len(name) > nics.MaxLinkNameLength {
+		return "", fmt.Errorf("link name %q must be between 1 and %d characters", name, nics.MaxLinkNameLength)
+	}
+	return name, nil
+}
+
+// routesForLink returns the routes of the PrivateNetwork to install on the link with the given mac address
+func routesForLink(n *nics.NICs, pnet *vpcv1alpha1.PrivateNetwork, mac string) ([]nics.Route, error) {
+	otherDefaultRoute := false
+	if pnet.Spec.Gateway != nil && pnet.Spec.Gateway.DefaultRoute {
+		var err error
+		otherDefaultRoute, err = n.HasOtherDefaultRoute(mac)
+		// a missing link gets no route anyway
+		if err != nil && !nics.IsNotFound(err) {
+			return nil, fmt.Errorf("unable to look for a default route: %w", err)
+		}
+	}
+	return routesFromPrivateNetwork(pnet, otherDefaultRoute)
+}
+
+// routesFromPrivateNetwork returns the routes of the PrivateNetwork
+// The default route through the Public Gateway is left out when the node already has one, as both would collide
+func routesFromPrivateNetwork(pnet *vpcv1alpha1.PrivateNetwork, otherDefaultRoute bool) ([]nics.Route, error) {
+	routes := []nics.Route{}
+	for _, route := range pnet.Spec.Routes {
+		via := net.ParseIP(route.Via)
+		to, err := netlink.ParseIPNet(route.To)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse to route %s: %w", route.To, err)
+		}
+		routes = append(routes, nics.Route{
+			To:  to,
+			Via: via,
+		})
+	}
+
+	if pnet.Spec.Gateway != nil && pnet.Status.GatewayAddress != "" {
+		via := net.ParseIP(pnet.Status.GatewayAddress)
+		if via == nil {
+			return nil, fmt.Errorf("unable to parse gateway address %s", pnet.Status.GatewayAddress)
+		}
+		destinations := pnet.Spec.Gateway.Routes
+		if pnet.Spec.Gateway.DefaultRoute && !otherDefaultRoute {
+			destinations = append([]string{"0.0.0.0/0"}, destinations...)
+		}
+		for _, destination := range destinations {
+			to, err := netlink.ParseIPNet(destination)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse gateway route %s: %w", destination, err)
+			}
+			routes = append(routes, nics.Route{
+				To:  to,
+				Via: via,
+			})
+		}
+	}
+	return routes, nil
+}
+
+// adoptableAddress returns the first address of the link in cidr, with its prefix length, or an empty string if there is none
+func adoptableAddress(addrs []*net.IPNet, cidr string) (string, error) {
+	_, cidrNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse cidr %s: %w", cidr, err)
+	}
+	for _, addr := range addrs {
+		if cidrNet.Contains(addr.IP) {
+			return addr.String(), nil
+		}
+	}
+	return "", nil
+}
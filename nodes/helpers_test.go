@@ -0,0 +1,235 @@
+package nodes
+
+import (
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+func TestLinkNameFromTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		pnName   string
+		pnID     string
+		expected string
+		err      bool
+	}{
+		{
+			name:     "name",
+			template: "pn-{{.Name}}",
+			pnName:   "db",
+			expected: "pn-db",
+		},
+		{
+			name:     "id prefix",
+			template: `{{printf "%.8s" .ID}}`,
+			pnName:   "db",
+			pnID:     "0f6e2c51-3b1c-4a4e-9b1e-2f4a4e0c4d11",
+			expected: "0f6e2c51",
+		},
+		{
+			name:     "max length",
+			template: "{{.Name}}",
+			pnName:   "abcdefghijklmno",
+			expected: "abcdefghijklmno",
+		},
+		{
+			name:     "too long",
+			template: "pn-{{.Name}}",
+			pnName:   "abcdefghijklmno",
+			err:      true,
+		},
+		{
+			name:     "empty",
+			template: "{{.ID}}",
+			pnName:   "db",
+			err:      true,
+		},
+		{
+			name:     "unknown key",
+			template: "{{.Unknown}}",
+			pnName:   "db",
+			err:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pn := &vpcv1alpha1.PrivateNetwork{
+				ObjectMeta: metav1.ObjectMeta{Name: tt.pnName},
+				Spec: vpcv1alpha1.PrivateNetworkSpec{
+					ID:       tt.pnID,
+					LinkName: tt.template,
+				},
+			}
+			name, err := linkNameFromTemplate(pn)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if name != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, name)
+			}
+		})
+	}
+}
+
+func TestRoutesFromPrivateNetwork(t *testing.T) {
+	gateway := func(defaultRoute bool, routes ...string) vpcv1alpha1.PrivateNetwork {
+		return vpcv1alpha1.PrivateNetwork{
+			Spec: vpcv1alpha1.PrivateNetworkSpec{
+				Routes: []vpcv1alpha1.PrivateNetworkRoute{{To: "10.10.0.0/16", Via: "192.168.0.254"}},
+				Gateway: &vpcv1alpha1.PrivateNetworkGateway{
+					ID:           "gw",
+					DefaultRoute: defaultRoute,
+					Routes:       routes,
+				},
+			},
+			Status: vpcv1alpha1.PrivateNetworkStatus{GatewayAddress: "192.168.0.1"},
+		}
+	}
+
+	tests := []struct {
+		name              string
+		pnet              vpcv1alpha1.PrivateNetwork
+		otherDefaultRoute bool
+		expected          []string
+		err               bool
+	}{
+		{
+			name:     "default route",
+			pnet:     gateway(true, "172.16.0.0/12"),
+			expected: []string{"0.0.0.0/0 via 192.168.0.1", "10.10.0.0/16 via 192.168.0.254", "172.16.0.0/12 via 192.168.0.1"},
+		},
+		{
+			name:              "node with a default route",
+			pnet:              gateway(true, "172.16.0.0/12"),
+			otherDefaultRoute: true,
+			expected:          []string{"10.10.0.0/16 via 192.168.0.254", "172.16.0.0/12 via 192.168.0.1"},
+		},
+		{
+			name:     "no default route",
+			pnet:     gateway(false),
+			expected: []string{"10.10.0.0/16 via 192.168.0.254"},
+		},
+		{
+			name: "gateway address not found yet",
+			pnet: func() vpcv1alpha1.PrivateNetwork {
+				pnet := gateway(true)
+				pnet.Status.GatewayAddress = ""
+				return pnet
+			}(),
+			expected: []string{"10.10.0.0/16 via 192.168.0.254"},
+		},
+		{
+			name: "invalid gateway route",
+			pnet: gateway(false, "172.16.0.0"),
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes, err := routesFromPrivateNetwork(&tt.pnet, tt.otherDefaultRoute)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got := []string{}
+			for _, route := range routes {
+				got = append(got, route.To.String()+" via "+route.Via.String())
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestAdoptableAddress(t *testing.T) {
+	parse := func(addrs ...string) []*net.IPNet {
+		ipNets := []*net.IPNet{}
+		for _, addr := range addrs {
+			ip, ipNet, err := net.ParseCIDR(addr)
+			if err != nil {
+				t.Fatalf("could not parse %s: %s", addr, err)
+			}
+			ipNet.IP = ip
+			ipNets = append(ipNets, ipNet)
+		}
+		return ipNets
+	}
+
+	tests := []struct {
+		name     string
+		addrs    []*net.IPNet
+		cidr     string
+		expected string
+		err      bool
+	}{
+		{
+			name:     "no address",
+			cidr:     "10.0.0.0/16",
+			expected: "",
+		},
+		{
+			name:     "address in the cidr",
+			addrs:    parse("10.0.3.4/16"),
+			cidr:     "10.0.0.0/16",
+			expected: "10.0.3.4/16",
+		},
+		{
+			name:     "address outside of the cidr",
+			addrs:    parse("10.1.3.4/16"),
+			cidr:     "10.0.0.0/16",
+			expected: "",
+		},
+		{
+			name:     "several candidate addresses",
+			addrs:    parse("fe80::1/64", "10.1.3.4/16", "10.0.3.4/24", "10.0.5.6/16"),
+			cidr:     "10.0.0.0/16",
+			expected: "10.0.3.4/24",
+		},
+		{
+			name:  "invalid cidr",
+			addrs: parse("10.0.3.4/16"),
+			cidr:  "10.0.0.0",
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := adoptableAddress(tt.addrs, tt.cidr)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+// LinkWatcher triggers a reconcile of the NetworkInterfaces of the node when their link is hotplugged,
+// when their link, addresses or routes are changed outside of the controller, and periodically for all of them
+type LinkWatcher struct {
+	client.Client
+	Log          logr.Logger
+	MetadataAPI  *instance.MetadataAPI
+	NodeName     string
+	NICs         *nics.NICs
+	ResyncPeriod time.Duration
+
+	// Events is consumed by the NetworkInterfaceReconciler
+	Events chan event.GenericEvent
+
+	// subscribe and refreshLinks default to NICs.Subscribe and refresh
+	subscribe    func(stopCh <-chan struct{}) (<-chan nics.Update, error)
+	refreshLinks func() error
+}
+
+// Start implements manager.Runnable
+func (w *LinkWatcher) Start(stopCh <-chan struct{}) error {
+	if w.subscribe == nil {
+		w.subscribe = w.NICs.Subscribe
+	}
+	if w.refreshLinks == nil {
+		w.refreshLinks = w.refresh
+	}
+
+	ticker := time.NewTicker(w.ResyncPeriod)
+	defer ticker.Stop()
+
+	var updates <-chan nics.Update
+	for {
+		if updates == nil {
+			var err error
+			updates, err = w.subscribe(stopCh)
+			if err != nil {
+				// a nil channel blocks, the subscription is retried on the next resync
+				w.Log.Error(err, "unable to subscribe to netlink updates")
+			}
+		}
+
+		select {
+		case <-stopCh:
+			return nil
+		case <-ticker.C:
+			w.enqueue(nics.Update{})
+		case update, ok := <-updates:
+			if !ok {
+				w.Log.Info("netlink subscription closed, subscribing again")
+				updates = nil
+				continue
+			}
+			w.enqueue(update)
+		}
+	}
+}
+
+// enqueue sends an event for the NetworkInterfaces of the node with the mac address of the update,
+// or for all of them if empty
+func (w *LinkWatcher) enqueue(update nics.Update) {
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := w.Client.List(context.Background(), nicsList,
+		client.MatchingLabels{
+			constants.NodeLabel: w.NodeName,
+		},
+	)
+	if err != nil {
+		w.Log.Error(err, "unable to list networkInterfaces for node")
+		return
+	}
+
+	matching := []*vpcv1alpha1.NetworkInterface{}
+	for i := range nicsList.Items {
+		nic := &nicsList.Items[i]
+		if update.MAC != "" && nic.Status.MacAddress != update.MAC {
+			continue
+		}
+		matching = append(matching, nic)
+	}
+
+	if len(matching) != 0 && update.Hotplug {
+		w.Log.Info(fmt.Sprintf("link %s was hotplugged, refreshing links", update.MAC))
+		err := w.refreshLinks()
+		if err != nil {
+			w.Log.Error(err, "unable to refresh links")
+		}
+	}
+
+	for _, nic := range matching {
+		if update.MAC != "" {
+			w.Log.V(1).Info(fmt.Sprintf("link %s changed, adding event for nic %s", update.MAC, nic.Name))
+		}
+		w.Events <- event.GenericEvent{
+			Meta:   nic,
+			Object: nic,
+		}
+	}
+}
+
+// refresh updates the known links from the private NICs in the metadata
+func (w *LinkWatcher) refresh() error {
+	md, err := w.MetadataAPI.GetMetadata()
+	if err != nil {
+		return err
+	}
+
+	macs := []string{}
+	for _, pn := range md.PrivateNICs {
+		macs = append(macs, pn.MacAddress)
+	}
+
+	return w.NICs.Refresh(macs)
+}
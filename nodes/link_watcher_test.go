@@ -0,0 +1,230 @@
+package nodes
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+const (
+	macA = "02:00:00:00:00:0a"
+	macB = "02:00:00:00:00:0b"
+)
+
+func linkWatcherNetworkInterface(name, nodeName, mac string) *vpcv1alpha1.NetworkInterface {
+	return &vpcv1alpha1.NetworkInterface{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				constants.NodeLabel: nodeName,
+			},
+		},
+		Status: vpcv1alpha1.NetworkInterfaceStatus{
+			MacAddress: mac,
+		},
+	}
+}
+
+// newTestLinkWatcher returns a LinkWatcher of node with a NetworkInterface for macA and macB, and another one
+// for macA on another node
+func newTestLinkWatcher(resyncPeriod time.Duration) *LinkWatcher {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = vpcv1alpha1.AddToScheme(scheme)
+
+	return &LinkWatcher{
+		Client: fake.NewFakeClientWithScheme(scheme,
+			linkWatcherNetworkInterface("nic-a", "node", macA),
+			linkWatcherNetworkInterface("nic-b", "node", macB),
+			linkWatcherNetworkInterface("nic-other", "other", macA),
+		),
+		Log:          ctrl.Log.WithName("test"),
+		NodeName:     "node",
+		ResyncPeriod: resyncPeriod,
+		Events:       make(chan event.GenericEvent, 10),
+	}
+}
+
+// startLinkWatcher starts w until the test ends
+func startLinkWatcher(t *testing.T, w *LinkWatcher) {
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = w.Start(stopCh)
+	}()
+	t.Cleanup(func() {
+		close(stopCh)
+		// a pending event would block the watcher
+		for {
+			select {
+			case <-done:
+				return
+			case <-w.Events:
+			}
+		}
+	})
+}
+
+// nextEvent returns the name of the NetworkInterface of the next event
+func nextEvent(t *testing.T, events <-chan event.GenericEvent) string {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e.Meta.GetName()
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+		return ""
+	}
+}
+
+func TestLinkWatcherUpdates(t *testing.T) {
+	w := newTestLinkWatcher(time.Hour)
+	updates := make(chan nics.Update)
+	w.subscribe = func(<-chan struct{}) (<-chan nics.Update, error) {
+		return updates, nil
+	}
+	var refreshes int32
+	w.refreshLinks = func() error {
+		atomic.AddInt32(&refreshes, 1)
+		return nil
+	}
+	startLinkWatcher(t, w)
+
+	tests := []struct {
+		name      string
+		update    nics.Update
+		expected  string
+		refreshes int32
+	}{
+		{
+			name:     "link of a networkInterface",
+			update:   nics.Update{MAC: macA},
+			expected: "nic-a",
+		},
+		{
+			name:      "hotplugged link of a networkInterface",
+			update:    nics.Update{MAC: macB, Hotplug: true},
+			expected:  "nic-b",
+			refreshes: 1,
+		},
+		{
+			name:      "link without networkInterface",
+			update:    nics.Update{MAC: "02:00:00:00:00:0c"},
+			refreshes: 1,
+		},
+		{
+			// a link of the node which is not a private NIC, there is nothing to refresh
+			name:      "hotplugged link without networkInterface",
+			update:    nics.Update{MAC: "02:00:00:00:00:0c", Hotplug: true},
+			refreshes: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updates <- tt.update
+			// the updates are handled in order, an update without event is followed by the one of macA
+			if tt.expected == "" {
+				updates <- nics.Update{MAC: macA}
+				tt.expected = "nic-a"
+			}
+			got := nextEvent(t, w.Events)
+			if got != tt.expected {
+				t.Errorf("expected an event for %s, got %s", tt.expected, got)
+			}
+			if r := atomic.LoadInt32(&refreshes); r != tt.refreshes {
+				t.Errorf("expected %d refreshes, got %d", tt.refreshes, r)
+			}
+			select {
+			case e := <-w.Events:
+				t.Errorf("unexpected event for %s", e.Meta.GetName())
+			default:
+			}
+		})
+	}
+}
+
+func TestLinkWatcherResync(t *testing.T) {
+	w := newTestLinkWatcher(10 * time.Millisecond)
+	w.subscribe = func(<-chan struct{}) (<-chan nics.Update, error) {
+		return make(chan nics.Update), nil
+	}
+	w.refreshLinks = func() error {
+		return errors.New("unexpected refresh")
+	}
+	startLinkWatcher(t, w)
+
+	// a resync enqueues all the networkInterfaces of the node, without refreshing the links
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		got[nextEvent(t, w.Events)] = true
+	}
+	if !got["nic-a"] || !got["nic-b"] {
+		t.Errorf("expected events for nic-a and nic-b, got %v", got)
+	}
+}
+
+func TestLinkWatcherResubscribe(t *testing.T) {
+	w := newTestLinkWatcher(50 * time.Millisecond)
+	w.refreshLinks = func() error {
+		return nil
+	}
+
+	// the first subscription fails, the second one is closed, the third one stays open
+	first := make(chan nics.Update)
+	close(first)
+	last := make(chan nics.Update)
+	subscriptions := make(chan int, 10)
+	var calls int32
+	w.subscribe = func(<-chan struct{}) (<-chan nics.Update, error) {
+		call := atomic.AddInt32(&calls, 1)
+		subscriptions <- int(call)
+		switch call {
+		case 1:
+			return nil, errors.New("netlink error")
+		case 2:
+			return first, nil
+		}
+		return last, nil
+	}
+	startLinkWatcher(t, w)
+
+	for _, expected := range []int{1, 2, 3} {
+		select {
+		case call := <-subscriptions:
+			if call != expected {
+				t.Fatalf("expected subscription %d, got %d", expected, call)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no subscription %d", expected)
+		}
+	}
+
+	// the updates of the new subscription are handled, without subscribing again
+	last <- nics.Update{MAC: macB}
+	for {
+		name := nextEvent(t, w.Events)
+		// skip the events of the resyncs
+		if name == "nic-b" {
+			break
+		}
+	}
+	// the watcher reads the subscription again instead of subscribing again
+	last <- nics.Update{MAC: macB}
+	if c := atomic.LoadInt32(&calls); c != 3 {
+		t.Errorf("expected 3 subscriptions, got %d", c)
+	}
+}
@@ -0,0 +1,206 @@
+package nodes
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+const (
+	metricsNamespace = "scaleway_k8s_vpc"
+	metricsSubsystem = "node"
+)
+
+var (
+	reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "reconcile_errors_total",
+		Help:      "Number of failed reconciles per NetworkInterface",
+	}, []string{"networkinterface"})
+
+	linkLabels = []string{"networkinterface", "privatenetwork"}
+
+	linkUpDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, metricsSubsystem, "link_up"),
+		"Whether the link of the NetworkInterface is up",
+		linkLabels, nil,
+	)
+	linkAddressConfiguredDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, metricsSubsystem, "link_address_configured"),
+		"Whether the address of the NetworkInterface is configured on its link",
+		linkLabels, nil,
+	)
+	linkRoutesDesiredDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, metricsSubsystem, "link_routes_desired"),
+		"Number of routes of the PrivateNetwork of the NetworkInterface",
+		linkLabels, nil,
+	)
+	linkRoutesInstalledDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, metricsSubsystem, "link_routes_installed"),
+		"Number of routes of the PrivateNetwork installed on the link of the NetworkInterface",
+		linkLabels, nil,
+	)
+	linkMasqueradeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, metricsSubsystem, "link_masquerade_rule_present"),
+		"Whether the masquerade rule for the link of the NetworkInterface was present at its last reconcile",
+		linkLabels, nil,
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcileErrors)
+}
+
+// MasqueradeRules records whether the masquerade rule of the link of each NetworkInterface is present, as left by
+// its last reconcile, so that the metrics don't run iptables on every scrape
+type MasqueradeRules struct {
+	lock    sync.RWMutex
+	present map[string]bool
+}
+
+// NewMasqueradeRules returns an empty MasqueradeRules
+func NewMasqueradeRules() *MasqueradeRules {
+	return &MasqueradeRules{
+		present: make(map[string]bool),
+	}
+}
+
+// Set records whether the masquerade rule of the NetworkInterface is present
+func (m *MasqueradeRules) Set(name string, present bool) {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.present[name] = present
+}
+
+// Present returns whether the masquerade rule of the NetworkInterface was present at its last reconcile
+func (m *MasqueradeRules) Present(name string) bool {
+	if m == nil {
+		return false
+	}
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.present[name]
+}
+
+// Forget stops recording the NetworkInterface
+func (m *MasqueradeRules) Forget(name string) {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.present, name)
+}
+
+// LinkCollector collects the state of the links of the NetworkInterfaces of the node
+type LinkCollector struct {
+	Client     client.Client
+	Log        logr.Logger
+	NodeName   string
+	NICs       *nics.NICs
+	Masquerade *MasqueradeRules
+}
+
+// Describe implements prometheus.Collector
+func (c *LinkCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- linkUpDesc
+	ch <- linkAddressConfiguredDesc
+	ch <- linkRoutesDesiredDesc
+	ch <- linkRoutesInstalledDesc
+	ch <- linkMasqueradeDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *LinkCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx := context.Background()
+
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := c.Client.List(ctx, nicsList,
+		client.MatchingLabels{
+			constants.NodeLabel: c.NodeName,
+		},
+	)
+	if err != nil {
+		c.Log.Error(err, "unable to list networkInterfaces for metrics")
+		return
+	}
+
+	for _, nic := range nicsList.Items {
+		if nic.Status.MacAddress == "" || len(nic.OwnerReferences) == 0 {
+			continue
+		}
+
+		pnet := vpcv1alpha1.PrivateNetwork{}
+		err := c.Client.Get(ctx, types.NamespacedName{Name: nic.OwnerReferences[0].Name}, &pnet)
+		if err != nil {
+			c.Log.Error(err, "unable to get private network for metrics")
+			continue
+		}
+
+		routes, err := routesForLink(c.NICs, &pnet, nic.Status.MacAddress)
+		if err != nil {
+			c.Log.Error(err, "unable to parse routes for metrics")
+			continue
+		}
+
+		status, err := c.NICs.GetLinkStatus(nic.Status.MacAddress, routes)
+		if err != nil && !nics.IsNotFound(err) {
+			c.Log.Error(err, "unable to get link status for metrics")
+			continue
+		}
+
+		address := nic.Status.Address
+		if pnet.Spec.IPAM == nil {
+			address = nic.Spec.Address
+		}
+
+		labels := []string{nic.Name, pnet.Name}
+		ch <- prometheus.MustNewConstMetric(linkUpDesc, prometheus.GaugeValue, boolToFloat(status.Up), labels...)
+		ch <- prometheus.MustNewConstMetric(linkAddressConfiguredDesc, prometheus.GaugeValue, boolToFloat(hasAddress(status.Addresses, address)), labels...)
+		ch <- prometheus.MustNewConstMetric(linkRoutesDesiredDesc, prometheus.GaugeValue, float64(len(routes)), labels...)
+		ch <- prometheus.MustNewConstMetric(linkRoutesInstalledDesc, prometheus.GaugeValue, float64(status.InstalledRoutes), labels...)
+		ch <- prometheus.MustNewConstMetric(linkMasqueradeDesc, prometheus.GaugeValue, boolToFloat(c.Masquerade.Present(nic.Name)), labels...)
+	}
+}
+
+// hasAddress returns whether address, with or without prefix length, is in addrs
+func hasAddress(addrs []*net.IPNet, address string) bool {
+	if address == "" {
+		return false
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		var err error
+		ip, _, err = net.ParseCIDR(address)
+		if err != nil {
+			return false
+		}
+	}
+	for _, addr := range addrs {
+		if addr.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -19,18 +19,19 @@ package nodes
 import (
 	"context"
 	"fmt"
-	"net"
 	"time"
 
 	"github.com/coreos/go-iptables/iptables"
 	"github.com/go-logr/logr"
 	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
-	"github.com/vishvananda/netlink"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -42,6 +43,9 @@ import (
 	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
 )
 
+// hotplugRequeueDuration is the fallback requeue duration when waiting for a nic to be hotplugged
+const hotplugRequeueDuration = time.Second * 30
+
 // NetworkInterfaceReconciler reconciles a NetworkInterface object (part running on all nodes)
 type NetworkInterfaceReconciler struct {
 	client.Client
@@ -50,13 +54,26 @@ type NetworkInterfaceReconciler struct {
 	MetadataAPI *instance.MetadataAPI
 	NodeName    string
 	NICs        *nics.NICs
+	LinkEvents  <-chan event.GenericEvent
+	Tracker     *ConfigurationTracker
+	Masquerade  *MasqueradeRules
+	Recorder    record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces,verbs=get;list;watch;patch
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces/status,verbs=get;patch
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=privatenetworks,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	res, err := r.reconcile(req)
+	if err != nil {
+		reconcileErrors.WithLabelValues(req.Name).Inc()
+	}
+	return res, err
+}
+
+func (r *NetworkInterfaceReconciler) reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
 	log := r.Log.WithValues("networkinterface", req.NamespacedName)
 
@@ -64,6 +81,8 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 
 	err := r.Client.Get(ctx, req.NamespacedName, nic)
 	if err != nil {
+		r.Tracker.Forget(req.Name)
+		r.Masquerade.Forget(req.Name)
 		log.Error(err, "could not find object")
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
@@ -72,6 +91,14 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 		return ctrl.Result{}, nil
 	}
 
+	if nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
+		// marked as configured once the whole reconcile succeeds
+		r.Tracker.Unconfigured(nic.Name)
+	} else {
+		r.Tracker.Forget(nic.Name)
+		r.Masquerade.Forget(nic.Name)
+	}
+
 	pnet := vpcv1alpha1.PrivateNetwork{}
 	err = r.Client.Get(ctx, types.NamespacedName{Name: nic.OwnerReferences[0].Name}, &pnet)
 	if err != nil {
@@ -82,11 +109,17 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 	log.Info(fmt.Sprintf("MacAddress : %s", nic.Status.MacAddress))
 
 	if nic.Status.MacAddress == "" {
-		log.Info("Pas de MacAddress !")
-		return ctrl.Result{RequeueAfter: time.Second * 1}, nil
+		// the status update from the controller will trigger a new reconcile
+		log.Info("mac address not set yet")
+		return ctrl.Result{}, nil
 	}
 
 	if !nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
+		if controllerutil.ContainsFinalizer(nic, constants.DetachFinalizerName) {
+			// the controller removes it once the node is drained
+			log.Info("waiting for the node to be drained")
+			return ctrl.Result{}, nil
+		}
 		if controllerutil.ContainsFinalizer(nic, constants.FinalizerName) {
 
 			if pnet.Spec.IPAM == nil {
@@ -97,7 +130,7 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 				}
 			} else {
 				switch pnet.Spec.IPAM.Type {
-				case vpcv1alpha1.IPAMTypeStatic:
+				case vpcv1alpha1.IPAMTypeStatic, vpcv1alpha1.IPAMTypeSubnet, vpcv1alpha1.IPAMTypeReserved:
 					err := r.NICs.TearDownStaticLink(nic.Status.MacAddress, nic.Status.Address)
 					if err != nil {
 						log.Error(err, "unable to configure link")
@@ -114,6 +147,28 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 				}
 			}
 
+			// the rule was added with the current link name, possibly templated, before it is restored
+			if nic.Status.LinkName != "" {
+				ip, err := iptables.New()
+				if err != nil {
+					log.Error(err, "unable to create iptables helper")
+					return ctrl.Result{}, err
+				}
+				err = ip.DeleteIfExists("nat", "POSTROUTING", "-o", nic.Status.LinkName, "-j", "MASQUERADE")
+				if err != nil {
+					log.Error(err, "unable to delete masquerade iptables rule")
+					return ctrl.Result{}, err
+				}
+			}
+
+			if nic.Status.OriginalLinkName != "" && nic.Status.OriginalLinkName != nic.Status.LinkName {
+				err := r.NICs.SetLinkName(nic.Status.MacAddress, nic.Status.OriginalLinkName)
+				if err != nil {
+					log.Error(err, fmt.Sprintf("unable to restore link name %s", nic.Status.OriginalLinkName))
+				}
+			}
+			r.NICs.Forget(nic.Status.MacAddress)
+
 			patch := client.MergeFrom(nic.DeepCopy())
 			controllerutil.RemoveFinalizer(nic, constants.FinalizerName)
 			err = r.Client.Patch(ctx, nic, patch)
@@ -122,6 +177,8 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 				return ctrl.Result{}, err
 			}
 		}
+		// the link is torn down, it must not be renamed or configured again
+		return ctrl.Result{}, nil
 	}
 
 	md, err := r.MetadataAPI.GetMetadata()
@@ -138,46 +195,125 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 		}
 	}
 	if !found {
-		err := fmt.Errorf("nic not found on node")
-		log.Error(err, "unable to find nic")
-		return ctrl.Result{}, err
+		// the link watcher will trigger a new reconcile once the nic is hotplugged
+		log.Info("nic not found in metadata, waiting for hotplug")
+		return ctrl.Result{RequeueAfter: hotplugRequeueDuration}, nil
 	}
 
 	linkName, err := r.NICs.GetLinkName(nic.Status.MacAddress)
 	log.Info(fmt.Sprintf("linkName : %s", linkName))
 	if err != nil {
+		if nics.IsNotFound(err) {
+			log.Info("link not found on node, waiting for hotplug")
+			return ctrl.Result{RequeueAfter: hotplugRequeueDuration}, nil
+		}
 		log.Error(err, "unable to get link")
 		return ctrl.Result{}, err
 	}
 
+	previousLinkName := nic.Status.LinkName
+	originalLinkName := nic.Status.OriginalLinkName
+	if originalLinkName == "" {
+		originalLinkName = linkName
+	}
+
+	desiredLinkName := originalLinkName
+	if pnet.Spec.LinkName != "" {
+		desiredLinkName, err = linkNameFromTemplate(&pnet)
+		if err != nil {
+			r.recordLinkConfigurationFailed(nic, linkName, err)
+			log.Error(err, fmt.Sprintf("unable to render link name template %s", pnet.Spec.LinkName))
+			return ctrl.Result{}, err
+		}
+	}
+
+	if desiredLinkName != linkName {
+		err := r.NICs.SetLinkName(nic.Status.MacAddress, desiredLinkName)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("unable to rename link %s to %s", linkName, desiredLinkName))
+			return ctrl.Result{}, err
+		}
+		log.Info(fmt.Sprintf("renamed link %s to %s", linkName, desiredLinkName))
+		linkName = desiredLinkName
+	}
+
 	patch := client.MergeFrom(nic.DeepCopy())
 	nic.Status.LinkName = linkName
+	nic.Status.OriginalLinkName = originalLinkName
 	err = r.Client.Status().Patch(ctx, nic, patch)
 	if err != nil {
 		log.Error(err, "unable to patch status")
 		return ctrl.Result{}, err
 	}
 
+	if pnet.AdoptsAddresses() && nic.Status.Address == "" {
+		if nic.Status.Adoption != nil || pnet.StaticCIDR() == "" {
+			// the status update from the controller will trigger a new reconcile
+			log.Info("waiting for the controller to adopt or allocate an address")
+			return ctrl.Result{}, nil
+		}
+		// report the address configured before the controller was installed, so that it is kept
+		linkStatus, err := r.NICs.GetLinkStatus(nic.Status.MacAddress, nil)
+		if err != nil {
+			log.Error(err, "unable to get link status")
+			return ctrl.Result{}, err
+		}
+		existing, err := adoptableAddress(linkStatus.Addresses, pnet.StaticCIDR())
+		if err != nil {
+			log.Error(err, "unable to find the address to adopt")
+			return ctrl.Result{}, err
+		}
+		patch := client.MergeFrom(nic.DeepCopy())
+		nic.Status.Adoption = &vpcv1alpha1.NetworkInterfaceAdoptionStatus{
+			Address: existing,
+		}
+		err = r.Client.Status().Patch(ctx, nic, patch)
+		if err != nil {
+			log.Error(err, "unable to patch status")
+			return ctrl.Result{}, err
+		}
+		log.Info(fmt.Sprintf("reported address %q of link %s for adoption", existing, linkName))
+		return ctrl.Result{}, nil
+	}
+
+	address := nic.Status.Address
 	if pnet.Spec.IPAM == nil {
-		err := r.NICs.ConfigureStaticLink(nic.Status.MacAddress, nic.Spec.Address)
+		address = nic.Spec.Address
+	}
+	// used to only send an event when the link actually needed to be configured
+	linkStatus, err := r.NICs.GetLinkStatus(nic.Status.MacAddress, nil)
+	if err != nil {
+		log.Error(err, "unable to get link status")
+		return ctrl.Result{}, err
+	}
+	wasConfigured := linkStatus.Up && hasAddress(linkStatus.Addresses, address)
+
+	mtu := int(pnet.Spec.MTU)
+
+	if pnet.Spec.IPAM == nil {
+		err := r.NICs.ConfigureStaticLink(nic.Status.MacAddress, nic.Spec.Address, mtu)
 		if err != nil {
+			r.recordLinkConfigurationFailed(nic, linkName, err)
 			log.Error(err, "unable to configure link")
 			return ctrl.Result{}, err
 		}
 	} else {
 		switch pnet.Spec.IPAM.Type {
-		case vpcv1alpha1.IPAMTypeStatic:
-			err := r.NICs.ConfigureStaticLink(nic.Status.MacAddress, nic.Status.Address)
+		case vpcv1alpha1.IPAMTypeStatic, vpcv1alpha1.IPAMTypeSubnet, vpcv1alpha1.IPAMTypeReserved:
+			err := r.NICs.ConfigureStaticLink(nic.Status.MacAddress, nic.Status.Address, mtu)
 			if err != nil {
+				r.recordLinkConfigurationFailed(nic, linkName, err)
 				log.Error(err, "unable to configure link")
 				return ctrl.Result{}, err
 			}
 		case vpcv1alpha1.IPAMTypeDHCP:
-			ip, err := r.NICs.ConfigureDHCPLink(nic.Status.MacAddress)
+			ip, err := r.NICs.ConfigureDHCPLink(nic.Status.MacAddress, mtu)
 			if err != nil {
+				r.recordLinkConfigurationFailed(nic, linkName, err)
 				log.Error(err, "unable to configure link")
 				return ctrl.Result{}, err
 			}
+			address = ip
 			patch := client.MergeFrom(nic.DeepCopy())
 			nic.Status.Address = ip
 			err = r.Client.Status().Patch(ctx, nic, patch)
@@ -190,6 +326,22 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 		}
 	}
 
+	effectiveMTU, err := r.NICs.GetLinkMTU(nic.Status.MacAddress)
+	if err != nil {
+		log.Error(err, "unable to get link MTU")
+		return ctrl.Result{}, err
+	}
+
+	if nic.Status.MTU != int32(effectiveMTU) {
+		patch := client.MergeFrom(nic.DeepCopy())
+		nic.Status.MTU = int32(effectiveMTU)
+		err = r.Client.Status().Patch(ctx, nic, patch)
+		if err != nil {
+			log.Error(err, "unable to patch status")
+			return ctrl.Result{}, err
+		}
+	}
+
 	ip, err := iptables.New()
 	if err != nil {
 		log.Error(err, "unable to create iptables helper")
@@ -202,6 +354,14 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 		return ctrl.Result{}, err
 	}
 
+	if previousLinkName != "" && previousLinkName != linkName {
+		err := ip.DeleteIfExists("nat", "POSTROUTING", "-o", previousLinkName, "-j", "MASQUERADE")
+		if err != nil {
+			log.Error(err, "unable to delete masquerade iptables rule for previous link name")
+			return ctrl.Result{}, err
+		}
+	}
+
 	if pnet.Spec.Masquerade && !isMasquerade {
 		err := ip.AppendUnique("nat", "POSTROUTING", "-o", linkName, "-j", "MASQUERADE")
 		if err != nil {
@@ -217,32 +377,43 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 			return ctrl.Result{}, err
 		}
 	}
+	r.Masquerade.Set(nic.Name, pnet.Spec.Masquerade)
 
-	routes := []nics.Route{}
-	for _, route := range pnet.Spec.Routes {
-		via := net.ParseIP(route.Via)
-		to, err := netlink.ParseIPNet(route.To)
-		if err != nil {
-			log.Error(err, fmt.Sprintf("unable to parse to route %s", route.To))
-			return ctrl.Result{}, err
-		}
-		routes = append(routes, nics.Route{
-			To:  to,
-			Via: via,
-		})
+	routes, err := routesForLink(r.NICs, &pnet, nic.Status.MacAddress)
+	if err != nil {
+		log.Error(err, "unable to parse routes")
+		return ctrl.Result{}, err
 	}
 
 	err = r.NICs.SyncRoutes(nic.Status.MacAddress, routes)
 	if err != nil {
+		r.Recorder.Event(nic, corev1.EventTypeWarning, constants.ReasonRouteSyncFailed, fmt.Sprintf("Could not sync routes on link %s: %s", linkName, err))
 		log.Error(err, "unable to sync routes")
 		return ctrl.Result{}, err
 	}
 
+	if nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
+		r.Tracker.Configured(nic.Name)
+		if !wasConfigured {
+			r.Recorder.Event(nic, corev1.EventTypeNormal, constants.ReasonLinkConfigured, fmt.Sprintf("Configured link %s with address %s on node %s", linkName, address, r.NodeName))
+		}
+	}
+
 	return ctrl.Result{}, nil
 }
 
-func (r *NetworkInterfaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+func (r *NetworkInterfaceReconciler) recordLinkConfigurationFailed(nic *vpcv1alpha1.NetworkInterface, linkName string, err error) {
+	r.Recorder.Event(nic, corev1.EventTypeWarning, constants.ReasonLinkConfigurationFailed, fmt.Sprintf("Could not configure link %s on node %s: %s", linkName, r.NodeName, err))
+}
+
+func (r *NetworkInterfaceReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	b := ctrl.NewControllerManagedBy(mgr).WithOptions(options)
+	if r.LinkEvents != nil {
+		b = b.Watches(&source.Channel{
+			Source: r.LinkEvents,
+		}, &handler.EnqueueRequestForObject{})
+	}
+	return b.
 		For(&vpcv1alpha1.NetworkInterface{}).
 		Watches(&source.Kind{
 			Type: &vpcv1alpha1.PrivateNetwork{},
@@ -0,0 +1,58 @@
+package ipam
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"net"
+	"sort"
+
+	goipam "github.com/metal-stack/go-ipam"
+)
+
+// PrefixIPs returns the addresses acquired in the prefix, sorted, without its network and broadcast addresses
+// go-ipam doesn't expose them, so they are decoded from the gob encoding of the prefix, which holds in order
+// the available child prefixes, the child prefix length, whether it is a parent, the IPs, the version, the CIDR and the parent CIDR
+func PrefixIPs(prefix *goipam.Prefix) ([]string, error) {
+	data, err := prefix.GobEncode()
+	if err != nil {
+		return nil, err
+	}
+
+	var availableChildPrefixes map[string]bool
+	var childPrefixLength int
+	var isParent bool
+	var ips map[string]bool
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	for _, v := range []interface{}{&availableChildPrefixes, &childPrefixLength, &isParent, &ips} {
+		if err := decoder.Decode(v); err != nil {
+			return nil, fmt.Errorf("unable to decode prefix %s: %w", prefix.Cidr, err)
+		}
+	}
+
+	_, ipNet, err := net.ParseCIDR(prefix.Cidr)
+	if err != nil {
+		return nil, err
+	}
+	network := ipNet.IP.String()
+	// go-ipam only reserves the broadcast address in IPv4
+	broadcast := ""
+	if ipNet.IP.To4() != nil {
+		b := make(net.IP, len(ipNet.IP))
+		for i := range ipNet.IP {
+			b[i] = ipNet.IP[i] | ^ipNet.Mask[i]
+		}
+		broadcast = b.String()
+	}
+
+	acquired := []string{}
+	for ip, ok := range ips {
+		if ok && ip != network && ip != broadcast {
+			acquired = append(acquired, ip)
+		}
+	}
+	sort.Slice(acquired, func(i, j int) bool {
+		return bytes.Compare(net.ParseIP(acquired[i]), net.ParseIP(acquired[j])) < 0
+	})
+	return acquired, nil
+}
@@ -0,0 +1,79 @@
+package ipam
+
+import (
+	"reflect"
+	"testing"
+
+	goipam "github.com/metal-stack/go-ipam"
+)
+
+func TestPrefixIPs(t *testing.T) {
+	tests := []struct {
+		name     string
+		cidr     string
+		acquire  []string
+		release  []string
+		expected []string
+	}{
+		{
+			name:     "empty",
+			cidr:     "10.0.0.0/24",
+			expected: []string{},
+		},
+		{
+			name:     "sorted",
+			cidr:     "10.0.0.0/24",
+			acquire:  []string{"10.0.0.10", "10.0.0.9", "10.0.0.100"},
+			expected: []string{"10.0.0.9", "10.0.0.10", "10.0.0.100"},
+		},
+		{
+			name:     "released",
+			cidr:     "10.0.0.0/24",
+			acquire:  []string{"10.0.0.1", "10.0.0.2"},
+			release:  []string{"10.0.0.1"},
+			expected: []string{"10.0.0.2"},
+		},
+		{
+			name:     "last IPv4 address",
+			cidr:     "10.0.0.0/30",
+			acquire:  []string{"10.0.0.1", "10.0.0.2"},
+			expected: []string{"10.0.0.1", "10.0.0.2"},
+		},
+		{
+			name:     "IPv6",
+			cidr:     "fd00::/126",
+			acquire:  []string{"fd00::3", "fd00::1"},
+			expected: []string{"fd00::1", "fd00::3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ipamer := goipam.New()
+			_, err := ipamer.NewPrefix(tt.cidr)
+			if err != nil {
+				t.Fatalf("could not create prefix: %s", err)
+			}
+			for _, ip := range tt.acquire {
+				_, err := ipamer.AcquireSpecificIP(tt.cidr, ip)
+				if err != nil {
+					t.Fatalf("could not acquire %s: %s", ip, err)
+				}
+			}
+			for _, ip := range tt.release {
+				err := ipamer.ReleaseIPFromPrefix(tt.cidr, ip)
+				if err != nil {
+					t.Fatalf("could not release %s: %s", ip, err)
+				}
+			}
+
+			ips, err := PrefixIPs(ipamer.PrefixFrom(tt.cidr))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(ips, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, ips)
+			}
+		})
+	}
+}
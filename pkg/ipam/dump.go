@@ -0,0 +1,83 @@
+package ipam
+
+import (
+	"fmt"
+	"sort"
+
+	goipam "github.com/metal-stack/go-ipam"
+)
+
+// Dump is the content of an IPAM storage
+type Dump struct {
+	Prefixes []PrefixDump `json:"prefixes"`
+}
+
+// PrefixDump is a prefix of an IPAM storage with its acquired addresses
+type PrefixDump struct {
+	CIDR string   `json:"cidr"`
+	IPs  []string `json:"ips"`
+}
+
+// DumpStorage returns the prefixes of the storage with their acquired addresses, sorted by CIDR
+func DumpStorage(storage goipam.Storage) (*Dump, error) {
+	prefixes, err := storage.ReadAllPrefixes()
+	if err != nil {
+		return nil, err
+	}
+
+	dump := &Dump{
+		Prefixes: make([]PrefixDump, 0, len(prefixes)),
+	}
+	for i := range prefixes {
+		ips, err := PrefixIPs(&prefixes[i])
+		if err != nil {
+			return nil, err
+		}
+		dump.Prefixes = append(dump.Prefixes, PrefixDump{
+			CIDR: prefixes[i].Cidr,
+			IPs:  ips,
+		})
+	}
+	sort.Slice(dump.Prefixes, func(i, j int) bool {
+		return dump.Prefixes[i].CIDR < dump.Prefixes[j].CIDR
+	})
+	return dump, nil
+}
+
+// Import creates the prefixes of the dump and acquires their addresses with ipamer, whose storage is storage
+// The prefixes and addresses already present are kept, so that an interrupted import can be run again
+func Import(ipamer goipam.Ipamer, storage goipam.Storage, dump *Dump) error {
+	for _, p := range dump.Prefixes {
+		existing := make(map[string]bool)
+		prefix, err := storage.ReadPrefix(p.CIDR)
+		if err == nil {
+			ips, err := PrefixIPs(&prefix)
+			if err != nil {
+				return err
+			}
+			for _, ip := range ips {
+				existing[ip] = true
+			}
+		} else {
+			_, err := ipamer.NewPrefix(p.CIDR)
+			if err != nil {
+				return fmt.Errorf("could not create prefix %s: %w", p.CIDR, err)
+			}
+		}
+
+		for _, ip := range p.IPs {
+			if existing[ip] {
+				continue
+			}
+			acquired, err := ipamer.AcquireSpecificIP(p.CIDR, ip)
+			if err != nil {
+				return fmt.Errorf("could not acquire IP %s in %s: %w", ip, p.CIDR, err)
+			}
+			// go-ipam returns no IP and no error when the address is already acquired
+			if acquired == nil {
+				return fmt.Errorf("could not acquire IP %s in %s: already acquired", ip, p.CIDR)
+			}
+		}
+	}
+	return nil
+}
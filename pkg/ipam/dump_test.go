@@ -0,0 +1,79 @@
+package ipam
+
+import (
+	"reflect"
+	"testing"
+
+	goipam "github.com/metal-stack/go-ipam"
+)
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing *Dump
+		dump     *Dump
+		expected *Dump
+	}{
+		{
+			name:     "empty storage",
+			existing: &Dump{},
+			dump: &Dump{Prefixes: []PrefixDump{
+				{CIDR: "10.0.0.0/24", IPs: []string{"10.0.0.1", "10.0.0.2"}},
+				{CIDR: "10.1.0.0/24", IPs: []string{}},
+			}},
+			expected: &Dump{Prefixes: []PrefixDump{
+				{CIDR: "10.0.0.0/24", IPs: []string{"10.0.0.1", "10.0.0.2"}},
+				{CIDR: "10.1.0.0/24", IPs: []string{}},
+			}},
+		},
+		{
+			name: "interrupted import",
+			existing: &Dump{Prefixes: []PrefixDump{
+				{CIDR: "10.0.0.0/24", IPs: []string{"10.0.0.1"}},
+			}},
+			dump: &Dump{Prefixes: []PrefixDump{
+				{CIDR: "10.0.0.0/24", IPs: []string{"10.0.0.1", "10.0.0.2"}},
+			}},
+			expected: &Dump{Prefixes: []PrefixDump{
+				{CIDR: "10.0.0.0/24", IPs: []string{"10.0.0.1", "10.0.0.2"}},
+			}},
+		},
+		{
+			name: "kept prefixes",
+			existing: &Dump{Prefixes: []PrefixDump{
+				{CIDR: "10.2.0.0/24", IPs: []string{"10.2.0.3"}},
+			}},
+			dump: &Dump{Prefixes: []PrefixDump{
+				{CIDR: "10.0.0.0/24", IPs: []string{"10.0.0.1"}},
+			}},
+			expected: &Dump{Prefixes: []PrefixDump{
+				{CIDR: "10.0.0.0/24", IPs: []string{"10.0.0.1"}},
+				{CIDR: "10.2.0.0/24", IPs: []string{"10.2.0.3"}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := goipam.NewMemory()
+			ipamer := goipam.NewWithStorage(storage)
+			err := Import(ipamer, storage, tt.existing)
+			if err != nil {
+				t.Fatalf("could not import existing dump: %s", err)
+			}
+
+			err = Import(ipamer, storage, tt.dump)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			dump, err := DumpStorage(storage)
+			if err != nil {
+				t.Fatalf("could not dump storage: %s", err)
+			}
+			if !reflect.DeepEqual(dump, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, dump)
+			}
+		})
+	}
+}
@@ -25,23 +25,27 @@ var (
 type ConfigMapIPAM struct {
 	name   types.NamespacedName
 	client client.Client
+	// reader reads the configmap from the API server, since go-ipam reads a prefix before updating it,
+	// a stale read from the cache would lose the previous updates
+	reader client.Reader
 
 	lock sync.RWMutex
 }
 
-func NewConfigMapIPAM(name types.NamespacedName, stopCh <-chan struct{}) (*ConfigMapIPAM, error) {
+// newConfigMapClients returns a client reading the configmaps of the namespace from a cache, and a client reading them from the API server
+func newConfigMapClients(namespace string, stopCh <-chan struct{}) (client.Client, client.Client, error) {
 	cmConfig := ctrl.GetConfigOrDie()
 	cmCache, err := cache.New(cmConfig, cache.Options{
-		Namespace: name.Namespace,
+		Namespace: namespace,
 	})
 	if err != nil {
 		ipamLog.Error(err, "unable to create cache for configmap")
-		return nil, err
+		return nil, nil, err
 	}
 	cmClient, err := client.New(cmConfig, client.Options{})
 	if err != nil {
 		ipamLog.Error(err, "unable to create client for configmap")
-		return nil, err
+		return nil, nil, err
 	}
 	cmCacheClient := &client.DelegatingClient{
 		Reader: &client.DelegatingReader{
@@ -64,7 +68,16 @@ func NewConfigMapIPAM(name types.NamespacedName, stopCh <-chan struct{}) (*Confi
 	defer cancel()
 	cacheOk := cmCache.WaitForCacheSync(ctx.Done())
 	if !cacheOk {
+		err := fmt.Errorf("timed out waiting for configmap cache")
 		ipamLog.Error(err, "unable to wait for configmap cache")
+		return nil, nil, err
+	}
+	return cmCacheClient, cmClient, nil
+}
+
+func NewConfigMapIPAM(name types.NamespacedName, stopCh <-chan struct{}) (*ConfigMapIPAM, error) {
+	cmCacheClient, cmClient, err := newConfigMapClients(name.Namespace, stopCh)
+	if err != nil {
 		return nil, err
 	}
 
@@ -95,6 +108,7 @@ func NewConfigMapIPAM(name types.NamespacedName, stopCh <-chan struct{}) (*Confi
 	return &ConfigMapIPAM{
 		name:   name,
 		client: cmCacheClient,
+		reader: cmClient,
 	}, nil
 }
 
@@ -122,7 +136,7 @@ func (c *ConfigMapIPAM) CreatePrefix(prefix goipam.Prefix) (goipam.Prefix, error
 			Namespace: c.name.Namespace,
 		},
 	}
-	err := c.client.Get(context.Background(), c.name, cm)
+	err := c.reader.Get(context.Background(), c.name, cm)
 	if err != nil {
 		return goipam.Prefix{}, err
 	}
@@ -140,7 +154,8 @@ func (c *ConfigMapIPAM) CreatePrefix(prefix goipam.Prefix) (goipam.Prefix, error
 		return goipam.Prefix{}, err
 	}
 
-	patch := client.MergeFrom(cm.DeepCopy())
+	// fail instead of overwriting a concurrent update
+	patch := client.MergeFromWithOptions(cm.DeepCopy(), client.MergeFromWithOptimisticLock{})
 	if cm.BinaryData == nil {
 		cm.BinaryData = make(map[string][]byte)
 	}
@@ -159,7 +174,7 @@ func (c *ConfigMapIPAM) ReadPrefix(prefix string) (goipam.Prefix, error) {
 	defer c.lock.RUnlock()
 
 	cm := &corev1.ConfigMap{}
-	err := c.client.Get(context.Background(), c.name, cm)
+	err := c.reader.Get(context.Background(), c.name, cm)
 	if err != nil {
 		return goipam.Prefix{}, err
 	}
@@ -201,7 +216,7 @@ func (c *ConfigMapIPAM) UpdatePrefix(prefix goipam.Prefix) (goipam.Prefix, error
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	cm := &corev1.ConfigMap{}
-	err := c.client.Get(context.Background(), c.name, cm)
+	err := c.reader.Get(context.Background(), c.name, cm)
 	if err != nil {
 		return goipam.Prefix{}, err
 	}
@@ -220,7 +235,8 @@ func (c *ConfigMapIPAM) UpdatePrefix(prefix goipam.Prefix) (goipam.Prefix, error
 		return goipam.Prefix{}, err
 	}
 
-	patch := client.MergeFrom(cm.DeepCopy())
+	// fail instead of overwriting a concurrent update
+	patch := client.MergeFromWithOptions(cm.DeepCopy(), client.MergeFromWithOptimisticLock{})
 	cm.BinaryData[getCmCIDR(prefix.Cidr)] = data
 
 	return prefix, c.client.Patch(context.Background(), cm, patch)
@@ -230,7 +246,7 @@ func (c *ConfigMapIPAM) DeletePrefix(prefix goipam.Prefix) (goipam.Prefix, error
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	cm := &corev1.ConfigMap{}
-	err := c.client.Get(context.Background(), c.name, cm)
+	err := c.reader.Get(context.Background(), c.name, cm)
 	if err != nil {
 		return goipam.Prefix{}, err
 	}
@@ -239,7 +255,8 @@ func (c *ConfigMapIPAM) DeletePrefix(prefix goipam.Prefix) (goipam.Prefix, error
 	if !ok {
 		return prefix, nil
 	}
-	patch := client.MergeFrom(cm.DeepCopy())
+	// fail instead of overwriting a concurrent update
+	patch := client.MergeFromWithOptions(cm.DeepCopy(), client.MergeFromWithOptimisticLock{})
 	delete(cm.BinaryData, getCmCIDR(prefix.Cidr))
 
 	return prefix, c.client.Patch(context.Background(), cm, patch)
@@ -0,0 +1,57 @@
+package ipam
+
+import (
+	"sync"
+
+	goipam "github.com/metal-stack/go-ipam"
+)
+
+// Locked is a goipam.Ipamer serializing the operations modifying the prefixes
+// go-ipam reads a prefix, updates it and writes it back, so concurrent reconciles could acquire the same IP
+type Locked struct {
+	goipam.Ipamer
+
+	lock sync.Mutex
+}
+
+// NewLocked returns a Locked wrapping ipamer
+func NewLocked(ipamer goipam.Ipamer) *Locked {
+	return &Locked{
+		Ipamer: ipamer,
+	}
+}
+
+// NewPrefix implements goipam.Ipamer
+func (l *Locked) NewPrefix(cidr string) (*goipam.Prefix, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.Ipamer.NewPrefix(cidr)
+}
+
+// DeletePrefix implements goipam.Ipamer
+func (l *Locked) DeletePrefix(cidr string) (*goipam.Prefix, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.Ipamer.DeletePrefix(cidr)
+}
+
+// AcquireIP implements goipam.Ipamer
+func (l *Locked) AcquireIP(prefixCidr string) (*goipam.IP, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.Ipamer.AcquireIP(prefixCidr)
+}
+
+// ReleaseIPFromPrefix implements goipam.Ipamer
+func (l *Locked) ReleaseIPFromPrefix(prefixCidr, ip string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.Ipamer.ReleaseIPFromPrefix(prefixCidr, ip)
+}
+
+// AcquireSpecificIP implements goipam.Ipamer
+func (l *Locked) AcquireSpecificIP(prefixCidr, specificIP string) (*goipam.IP, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.Ipamer.AcquireSpecificIP(prefixCidr, specificIP)
+}
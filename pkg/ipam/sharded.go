@@ -0,0 +1,246 @@
+package ipam
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	goipam "github.com/metal-stack/go-ipam"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ShardLabel is set on the configmaps of a ShardedConfigMapIPAM, with its name as value
+	ShardLabel = "vpc.scaleway.com/ipam"
+
+	// shardDataKey is the key of the prefix in the configmap of a shard
+	shardDataKey = "prefix"
+)
+
+// ShardedConfigMapIPAM is a goipam.Storage keeping each prefix in its own configmap, labelled with ShardLabel,
+// so that a large prefix doesn't hit the size limit of a configmap, and an allocation only rewrites its prefix
+type ShardedConfigMapIPAM struct {
+	name   types.NamespacedName
+	client client.Client
+	// reader reads the configmaps from the API server, since go-ipam reads a prefix before updating it,
+	// a stale read from the cache would lose the previous updates
+	reader client.Reader
+
+	lock sync.RWMutex
+}
+
+// NewShardedConfigMapIPAM returns a ShardedConfigMapIPAM storing the prefixes in the configmaps of the namespace
+// named after name and the prefix
+func NewShardedConfigMapIPAM(name types.NamespacedName, stopCh <-chan struct{}) (*ShardedConfigMapIPAM, error) {
+	cmCacheClient, cmClient, err := newConfigMapClients(name.Namespace, stopCh)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ShardedConfigMapIPAM{
+		name:   name,
+		client: cmCacheClient,
+		reader: cmClient,
+	}, nil
+}
+
+// shardName returns the name of the configmap of the prefix
+func (s *ShardedConfigMapIPAM) shardName(cidr string) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: s.name.Namespace,
+		Name:      s.name.Name + "-" + strings.ToLower(strings.NewReplacer("/", "-", ":", "-").Replace(cidr)),
+	}
+}
+
+// readShard returns the configmap of the prefix and the prefix from the API server
+func (s *ShardedConfigMapIPAM) readShard(cidr string) (*corev1.ConfigMap, *goipam.Prefix, error) {
+	cm := &corev1.ConfigMap{}
+	err := s.reader.Get(context.Background(), s.shardName(cidr), cm)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil, fmt.Errorf("prefix %s not found", cidr)
+		}
+		return nil, nil, err
+	}
+	data, ok := cm.BinaryData[shardDataKey]
+	if !ok {
+		return nil, nil, fmt.Errorf("prefix %s not found in configmap %s", cidr, cm.Name)
+	}
+	prefix, err := decode(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cm, prefix, nil
+}
+
+func (s *ShardedConfigMapIPAM) CreatePrefix(prefix goipam.Prefix) (goipam.Prefix, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.createPrefix(prefix)
+}
+
+func (s *ShardedConfigMapIPAM) createPrefix(prefix goipam.Prefix) (goipam.Prefix, error) {
+	name := s.shardName(prefix.Cidr)
+	cm := &corev1.ConfigMap{}
+	err := s.reader.Get(context.Background(), name, cm)
+	if err == nil {
+		p, err := decode(cm.BinaryData[shardDataKey])
+		if err != nil {
+			return goipam.Prefix{}, err
+		}
+		// another CIDR may map to the same configmap name, e.g. with a different case
+		if p.Cidr != prefix.Cidr {
+			return goipam.Prefix{}, fmt.Errorf("configmap %s already holds prefix %s", name.Name, p.Cidr)
+		}
+		return *p, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return goipam.Prefix{}, err
+	}
+
+	data, err := encode(&prefix)
+	if err != nil {
+		return goipam.Prefix{}, err
+	}
+
+	cm = &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name.Name,
+			Namespace: name.Namespace,
+			Labels: map[string]string{
+				ShardLabel: s.name.Name,
+			},
+		},
+		BinaryData: map[string][]byte{
+			shardDataKey: data,
+		},
+	}
+	// fails if the prefix was created concurrently
+	err = s.client.Create(context.Background(), cm)
+	if err != nil {
+		return goipam.Prefix{}, err
+	}
+
+	return prefix, nil
+}
+
+func (s *ShardedConfigMapIPAM) ReadPrefix(prefix string) (goipam.Prefix, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	_, p, err := s.readShard(prefix)
+	if err != nil {
+		return goipam.Prefix{}, err
+	}
+	return *p, nil
+}
+
+func (s *ShardedConfigMapIPAM) ReadAllPrefixes() ([]goipam.Prefix, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	cmsList := &corev1.ConfigMapList{}
+	// the cache may miss the prefixes just created
+	err := s.reader.List(context.Background(), cmsList, client.InNamespace(s.name.Namespace), client.MatchingLabels{
+		ShardLabel: s.name.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ps := make([]goipam.Prefix, 0, len(cmsList.Items))
+	for _, cm := range cmsList.Items {
+		p, err := decode(cm.BinaryData[shardDataKey])
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode configmap %s: %w", cm.Name, err)
+		}
+		ps = append(ps, *p)
+	}
+	return ps, nil
+}
+
+func (s *ShardedConfigMapIPAM) UpdatePrefix(prefix goipam.Prefix) (goipam.Prefix, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if prefix.Cidr == "" {
+		return goipam.Prefix{}, fmt.Errorf("prefix not present:%v", prefix)
+	}
+
+	cm, _, err := s.readShard(prefix.Cidr)
+	if err != nil {
+		return goipam.Prefix{}, err
+	}
+
+	data, err := encode(&prefix)
+	if err != nil {
+		return goipam.Prefix{}, err
+	}
+
+	// fail instead of overwriting a concurrent update
+	patch := client.MergeFromWithOptions(cm.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	cm.BinaryData[shardDataKey] = data
+
+	return prefix, s.client.Patch(context.Background(), cm, patch)
+}
+
+func (s *ShardedConfigMapIPAM) DeletePrefix(prefix goipam.Prefix) (goipam.Prefix, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	name := s.shardName(prefix.Cidr)
+	err := s.client.Delete(context.Background(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name.Name,
+			Namespace: name.Namespace,
+		},
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return goipam.Prefix{}, err
+	}
+	return prefix, nil
+}
+
+// MigrateFrom moves the prefixes of the configmap of a ConfigMapIPAM to their own configmaps, and deletes it
+// The prefixes already sharded are kept, so that an interrupted migration can be run again
+// It must be called before the storage is used, while nothing else updates the configmap
+func (s *ShardedConfigMapIPAM) MigrateFrom(name types.NamespacedName) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	cm := &corev1.ConfigMap{}
+	err := s.reader.Get(context.Background(), name, cm)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	for key, data := range cm.BinaryData {
+		prefix, err := decode(data)
+		if err != nil {
+			return fmt.Errorf("unable to decode prefix %s: %w", key, err)
+		}
+		_, err = s.createPrefix(*prefix)
+		if err != nil {
+			return fmt.Errorf("unable to migrate prefix %s: %w", prefix.Cidr, err)
+		}
+		ipamLog.Info(fmt.Sprintf("migrated prefix %s from configmap %s", prefix.Cidr, name))
+	}
+
+	// the resource version makes sure no prefix was added during the migration
+	err = s.client.Delete(context.Background(), cm, client.Preconditions{
+		ResourceVersion: &cm.ResourceVersion,
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	ipamLog.Info(fmt.Sprintf("deleted configmap %s after migration", name))
+	return nil
+}
@@ -0,0 +1,257 @@
+package ipam
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	goipam "github.com/metal-stack/go-ipam"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var shardedName = types.NamespacedName{Namespace: "kube-system", Name: "ipam"}
+
+// staleCache is a cache which has not seen any configmap yet
+type staleCache struct {
+	client.Client
+}
+
+func (c staleCache) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	return apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, key.Name)
+}
+
+func (c staleCache) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	return nil
+}
+
+// newTestShardedConfigMapIPAM returns a ShardedConfigMapIPAM with a stale cache, holding objs
+func newTestShardedConfigMapIPAM(objs ...runtime.Object) (*ShardedConfigMapIPAM, client.Client) {
+	c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme, objs...)
+	return &ShardedConfigMapIPAM{
+		name:   shardedName,
+		client: staleCache{c},
+		reader: c,
+	}, c
+}
+
+// testPrefix returns the prefix with the IPs acquired
+func testPrefix(t *testing.T, cidr string, ips ...string) goipam.Prefix {
+	storage := goipam.NewMemory()
+	err := Import(goipam.NewWithStorage(storage), storage, &Dump{Prefixes: []PrefixDump{{CIDR: cidr, IPs: ips}}})
+	if err != nil {
+		t.Fatalf("could not create prefix %s: %s", cidr, err)
+	}
+	prefix, err := storage.ReadPrefix(cidr)
+	if err != nil {
+		t.Fatalf("could not read prefix %s: %s", cidr, err)
+	}
+	return prefix
+}
+
+// shard returns the configmap of the ShardedConfigMapIPAM holding prefix under the name of cidr
+func shard(t *testing.T, cidr string, prefix goipam.Prefix) *corev1.ConfigMap {
+	data, err := encode(&prefix)
+	if err != nil {
+		t.Fatalf("could not encode prefix %s: %s", prefix.Cidr, err)
+	}
+	name := (&ShardedConfigMapIPAM{name: shardedName}).shardName(cidr)
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name.Name,
+			Namespace: name.Namespace,
+			Labels:    map[string]string{ShardLabel: shardedName.Name},
+		},
+		BinaryData: map[string][]byte{shardDataKey: data},
+	}
+}
+
+func TestShardedConfigMapIPAMCreatePrefix(t *testing.T) {
+	tests := []struct {
+		name     string
+		objs     []runtime.Object
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "new prefix",
+			expected: []string{},
+		},
+		{
+			name:     "existing prefix",
+			objs:     []runtime.Object{shard(t, "10.0.0.0/24", testPrefix(t, "10.0.0.0/24", "10.0.0.1"))},
+			expected: []string{"10.0.0.1"},
+		},
+		{
+			name: "other prefix in the configmap",
+			objs: []runtime.Object{shard(t, "10.0.0.0/24", testPrefix(t, "10.1.0.0/24", "10.1.0.1"))},
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, _ := newTestShardedConfigMapIPAM(tt.objs...)
+			prefix, err := storage.CreatePrefix(testPrefix(t, "10.0.0.0/24"))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			ips, err := PrefixIPs(&prefix)
+			if err != nil {
+				t.Fatalf("could not get IPs of prefix: %s", err)
+			}
+			if !reflect.DeepEqual(ips, tt.expected) {
+				t.Errorf("expected IPs %v, got %v", tt.expected, ips)
+			}
+
+			// the prefix is stored, whatever the cache has seen
+			stored, err := storage.ReadPrefix("10.0.0.0/24")
+			if err != nil {
+				t.Fatalf("could not read prefix: %s", err)
+			}
+			if stored.Cidr != "10.0.0.0/24" {
+				t.Errorf("expected prefix 10.0.0.0/24, got %s", stored.Cidr)
+			}
+		})
+	}
+}
+
+func TestShardedConfigMapIPAMAllocations(t *testing.T) {
+	storage, _ := newTestShardedConfigMapIPAM()
+	ipamer := goipam.NewWithStorage(storage)
+
+	for _, cidr := range []string{"10.0.0.0/24", "fd00::/120"} {
+		_, err := ipamer.NewPrefix(cidr)
+		if err != nil {
+			t.Fatalf("could not create prefix %s: %s", cidr, err)
+		}
+	}
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		_, err := ipamer.AcquireSpecificIP("10.0.0.0/24", ip)
+		if err != nil {
+			t.Fatalf("could not acquire %s: %s", ip, err)
+		}
+	}
+	_, err := ipamer.AcquireIP("fd00::/120")
+	if err != nil {
+		t.Fatalf("could not acquire IP: %s", err)
+	}
+	err = ipamer.ReleaseIPFromPrefix("10.0.0.0/24", "10.0.0.1")
+	if err != nil {
+		t.Fatalf("could not release IP: %s", err)
+	}
+
+	dump, err := DumpStorage(storage)
+	if err != nil {
+		t.Fatalf("could not dump storage: %s", err)
+	}
+	expected := &Dump{Prefixes: []PrefixDump{
+		{CIDR: "10.0.0.0/24", IPs: []string{"10.0.0.2"}},
+		{CIDR: "fd00::/120", IPs: []string{"fd00::1"}},
+	}}
+	if !reflect.DeepEqual(dump, expected) {
+		t.Errorf("expected %+v, got %+v", expected, dump)
+	}
+
+	err = ipamer.ReleaseIPFromPrefix("fd00::/120", "fd00::1")
+	if err != nil {
+		t.Fatalf("could not release IP: %s", err)
+	}
+	_, err = ipamer.DeletePrefix("fd00::/120")
+	if err != nil {
+		t.Fatalf("could not delete prefix: %s", err)
+	}
+	prefixes, err := storage.ReadAllPrefixes()
+	if err != nil {
+		t.Fatalf("could not read prefixes: %s", err)
+	}
+	if len(prefixes) != 1 || prefixes[0].Cidr != "10.0.0.0/24" {
+		t.Errorf("expected only prefix 10.0.0.0/24, got %+v", prefixes)
+	}
+}
+
+func TestShardedConfigMapIPAMMigrateFrom(t *testing.T) {
+	legacy := func(prefixes ...goipam.Prefix) *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: shardedName.Namespace},
+			BinaryData: map[string][]byte{},
+		}
+		for _, prefix := range prefixes {
+			prefix := prefix
+			data, err := encode(&prefix)
+			if err != nil {
+				t.Fatalf("could not encode prefix %s: %s", prefix.Cidr, err)
+			}
+			cm.BinaryData[getCmCIDR(prefix.Cidr)] = data
+		}
+		return cm
+	}
+
+	tests := []struct {
+		name     string
+		objs     []runtime.Object
+		expected *Dump
+	}{
+		{
+			name:     "no configmap",
+			expected: &Dump{Prefixes: []PrefixDump{}},
+		},
+		{
+			name: "migration",
+			objs: []runtime.Object{legacy(testPrefix(t, "10.0.0.0/24", "10.0.0.1"), testPrefix(t, "10.1.0.0/24"))},
+			expected: &Dump{Prefixes: []PrefixDump{
+				{CIDR: "10.0.0.0/24", IPs: []string{"10.0.0.1"}},
+				{CIDR: "10.1.0.0/24", IPs: []string{}},
+			}},
+		},
+		{
+			name: "interrupted migration",
+			objs: []runtime.Object{
+				legacy(testPrefix(t, "10.0.0.0/24", "10.0.0.1"), testPrefix(t, "10.1.0.0/24")),
+				shard(t, "10.0.0.0/24", testPrefix(t, "10.0.0.0/24", "10.0.0.1", "10.0.0.2")),
+			},
+			expected: &Dump{Prefixes: []PrefixDump{
+				{CIDR: "10.0.0.0/24", IPs: []string{"10.0.0.1", "10.0.0.2"}},
+				{CIDR: "10.1.0.0/24", IPs: []string{}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, c := newTestShardedConfigMapIPAM(tt.objs...)
+			name := types.NamespacedName{Namespace: shardedName.Namespace, Name: "legacy"}
+			err := storage.MigrateFrom(name)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			err = c.Get(context.Background(), name, &corev1.ConfigMap{})
+			if !apierrors.IsNotFound(err) {
+				t.Errorf("expected configmap %s to be deleted, got %v", name, err)
+			}
+
+			dump, err := DumpStorage(storage)
+			if err != nil {
+				t.Fatalf("could not dump storage: %s", err)
+			}
+			if !reflect.DeepEqual(dump, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, dump)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package ipam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	goipam "github.com/metal-stack/go-ipam"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// StorageConfigMap stores all the prefixes in a single configmap
+	StorageConfigMap = "configmap"
+	// StorageShardedConfigMap stores each prefix in its own configmap
+	StorageShardedConfigMap = "sharded-configmap"
+)
+
+// ErrReadOnly is returned by the writes to a storage opened with OpenStorage
+var ErrReadOnly = errors.New("ipam storage is read-only")
+
+// NewStorage returns the storage of the given kind, named after name
+func NewStorage(kind string, name types.NamespacedName, stopCh <-chan struct{}) (goipam.Storage, error) {
+	switch kind {
+	case StorageConfigMap:
+		return NewConfigMapIPAM(name, stopCh)
+	case StorageShardedConfigMap:
+		return NewShardedConfigMapIPAM(name, stopCh)
+	default:
+		return nil, fmt.Errorf("unknown ipam storage %s, expected %s or %s", kind, StorageConfigMap, StorageShardedConfigMap)
+	}
+}
+
+// OpenStorage returns the existing storage of the given kind, named after name, in read-only mode
+// The configmaps are read from the API server, without cache, and never created, so that a storage can be
+// inspected while the controller is running
+func OpenStorage(kind string, name types.NamespacedName) (goipam.Storage, error) {
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{})
+	if err != nil {
+		return nil, err
+	}
+	return openStorage(c, kind, name)
+}
+
+func openStorage(c client.Client, kind string, name types.NamespacedName) (goipam.Storage, error) {
+	switch kind {
+	case StorageConfigMap:
+		err := c.Get(context.Background(), name, &corev1.ConfigMap{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("ipam configmap %s not found", name)
+			}
+			return nil, err
+		}
+		return readOnlyStorage{&ConfigMapIPAM{
+			name:   name,
+			client: c,
+			reader: c,
+		}}, nil
+	case StorageShardedConfigMap:
+		return readOnlyStorage{&ShardedConfigMapIPAM{
+			name:   name,
+			client: c,
+			reader: c,
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unknown ipam storage %s, expected %s or %s", kind, StorageConfigMap, StorageShardedConfigMap)
+	}
+}
+
+// readOnlyStorage is a goipam.Storage failing all the writes with ErrReadOnly
+type readOnlyStorage struct {
+	goipam.Storage
+}
+
+func (readOnlyStorage) CreatePrefix(prefix goipam.Prefix) (goipam.Prefix, error) {
+	return goipam.Prefix{}, ErrReadOnly
+}
+
+func (readOnlyStorage) UpdatePrefix(prefix goipam.Prefix) (goipam.Prefix, error) {
+	return goipam.Prefix{}, ErrReadOnly
+}
+
+func (readOnlyStorage) DeletePrefix(prefix goipam.Prefix) (goipam.Prefix, error) {
+	return goipam.Prefix{}, ErrReadOnly
+}
@@ -0,0 +1,101 @@
+package ipam
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestOpenStorage(t *testing.T) {
+	name := types.NamespacedName{Namespace: "kube-system", Name: "ipam"}
+	prefix := testPrefix(t, "10.0.0.0/24", "10.0.0.1")
+	data, err := encode(&prefix)
+	if err != nil {
+		t.Fatalf("could not encode prefix: %s", err)
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace},
+		BinaryData: map[string][]byte{getCmCIDR(prefix.Cidr): data},
+	}
+
+	tests := []struct {
+		name    string
+		kind    string
+		objs    []corev1.ConfigMap
+		missing bool
+	}{
+		{
+			name: "configmap",
+			kind: StorageConfigMap,
+			objs: []corev1.ConfigMap{*cm},
+		},
+		{
+			name:    "missing configmap",
+			kind:    StorageConfigMap,
+			missing: true,
+		},
+		{
+			name: "sharded configmap",
+			kind: StorageShardedConfigMap,
+			objs: []corev1.ConfigMap{*shard(t, prefix.Cidr, prefix)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme)
+			for i := range tt.objs {
+				if err := c.Create(context.Background(), &tt.objs[i]); err != nil {
+					t.Fatalf("could not create configmap: %s", err)
+				}
+			}
+
+			storage, err := openStorage(c, tt.kind, name)
+			if tt.missing {
+				if err == nil {
+					t.Fatalf("expected an error for a missing configmap")
+				}
+				// the configmap is never created
+				err := c.Get(context.Background(), name, &corev1.ConfigMap{})
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("expected the configmap not to be created, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			dump, err := DumpStorage(storage)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			expected := &Dump{Prefixes: []PrefixDump{{CIDR: "10.0.0.0/24", IPs: []string{"10.0.0.1"}}}}
+			if !reflect.DeepEqual(dump, expected) {
+				t.Errorf("expected %+v, got %+v", expected, dump)
+			}
+
+			if _, err := storage.CreatePrefix(testPrefix(t, "10.1.0.0/24")); !errors.Is(err, ErrReadOnly) {
+				t.Errorf("expected the creation to be refused, got %v", err)
+			}
+			if _, err := storage.UpdatePrefix(prefix); !errors.Is(err, ErrReadOnly) {
+				t.Errorf("expected the update to be refused, got %v", err)
+			}
+			if _, err := storage.DeletePrefix(prefix); !errors.Is(err, ErrReadOnly) {
+				t.Errorf("expected the deletion to be refused, got %v", err)
+			}
+		})
+	}
+
+	if _, err := openStorage(fake.NewFakeClientWithScheme(clientgoscheme.Scheme), "etcd", name); err == nil {
+		t.Errorf("expected an error for an unknown storage")
+	}
+}
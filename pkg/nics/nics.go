@@ -6,6 +6,8 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"strings"
+	"sync"
 
 	"github.com/vishvananda/netlink"
 )
@@ -13,8 +15,14 @@ import (
 const (
 	dhcpcdRunFilePrefix = "/var/run/dhcpcd-"
 	dhcpcdRunFileSuffix = "-4.pid"
+
+	minMTU = 1280
+	maxMTU = 8896
 )
 
+// MaxLinkNameLength is IFNAMSIZ minus the trailing NUL
+const MaxLinkNameLength = 15
+
 var (
 	nicNotFoundErr = errors.New("NIC not found")
 )
@@ -24,9 +32,20 @@ type Route struct {
 	Via net.IP
 }
 
+// routeDst returns the destination of the route, the default routes having no destination
+func routeDst(route netlink.Route) string {
+	if route.Dst != nil {
+		return route.Dst.String()
+	}
+	if route.Gw != nil && route.Gw.To4() == nil {
+		return "::/0"
+	}
+	return "0.0.0.0/0"
+}
+
 func (r Route) isIn(routes []netlink.Route) bool {
 	for _, route := range routes {
-		if route.Dst.String() == r.To.String() && route.Gw.Equal(r.Via) {
+		if routeDst(route) == r.To.String() && route.Gw.Equal(r.Via) {
 			return true
 		}
 	}
@@ -35,16 +54,30 @@ func (r Route) isIn(routes []netlink.Route) bool {
 
 func isIn(r netlink.Route, routes []Route) bool {
 	for _, route := range routes {
-		if r.Dst.String() == route.To.String() && r.Gw.Equal(route.Via) {
+		if routeDst(r) == route.To.String() && r.Gw.Equal(route.Via) {
 			return true
 		}
 	}
 	return false
 }
 
+// linkLister looks up the links, it is the netlink.Handle of the NICs outside of the tests
+type linkLister interface {
+	LinkList() ([]netlink.Link, error)
+	LinkByIndex(index int) (netlink.Link, error)
+}
+
 type NICs struct {
 	Handle *netlink.Handle
 	Links  map[string]netlink.Link
+
+	// lister looks up the links on Handle
+	lister linkLister
+
+	// lock protects Links
+	lock sync.RWMutex
+	// handleLock serializes the requests on Handle, its sockets are not safe for concurrent use
+	handleLock sync.Mutex
 }
 
 func NewNICs(macs []string) (*NICs, error) {
@@ -56,23 +89,69 @@ func NewNICs(macs []string) (*NICs, error) {
 	nics := &NICs{
 		Handle: handle,
 		Links:  make(map[string]netlink.Link),
+		lister: handle,
 	}
 
-	links, err := handle.LinkList()
+	err = nics.Refresh(macs)
 	if err != nil {
 		return nil, err
 	}
 
+	return nics, nil
+}
+
+// Refresh replaces the known links by the current links with the given mac addresses
+func (n *NICs) Refresh(macs []string) error {
+	// use a dedicated netlink socket, as it can be called outside of the reconciler
+	links, err := netlink.LinkList()
+	if err != nil {
+		return err
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.Links = make(map[string]netlink.Link)
 	for _, link := range links {
 		for _, mac := range macs {
 			if link.Attrs().HardwareAddr.String() == mac {
-				nics.Links[mac] = link
+				n.Links[mac] = link
 				break
 			}
 		}
 	}
 
-	return nics, nil
+	return nil
+}
+
+// IsNotFound returns whether the error is due to a missing link
+func IsNotFound(err error) bool {
+	return errors.Is(err, nicNotFoundErr)
+}
+
+func (n *NICs) setLink(mac string, link netlink.Link) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.Links[mac] = link
+}
+
+// Forget drops the known link with the given mac address, it will be looked up again on next use
+func (n *NICs) Forget(mac string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	delete(n.Links, mac)
+}
+
+func (n *NICs) linkList() ([]netlink.Link, error) {
+	n.handleLock.Lock()
+	defer n.handleLock.Unlock()
+	return n.lister.LinkList()
+}
+
+func (n *NICs) linkByIndex(index int) (netlink.Link, error) {
+	n.handleLock.Lock()
+	defer n.handleLock.Unlock()
+	return n.lister.LinkByIndex(index)
 }
 
 func (n *NICs) GetLinkName(mac string) (string, error) {
@@ -83,19 +162,34 @@ func (n *NICs) GetLinkName(mac string) (string, error) {
 	return link.Attrs().Name, nil
 }
 
+// getLink returns the current link with the given mac address
+// The known link is checked against the current index and mac address, as the NIC may have been detached and
+// attached again with another index
 func (n *NICs) getLink(mac string) (netlink.Link, error) {
-	if link, ok := n.Links[mac]; ok {
-		return link, nil
+	n.lock.RLock()
+	cached, ok := n.Links[mac]
+	n.lock.RUnlock()
+	if ok {
+		current, err := n.linkByIndex(cached.Attrs().Index)
+		if err == nil && current.Attrs().HardwareAddr.String() == mac {
+			n.setLink(mac, current)
+			return current, nil
+		}
+		var notFound netlink.LinkNotFoundError
+		if err != nil && !errors.As(err, &notFound) {
+			return nil, err
+		}
+		n.Forget(mac)
 	}
 
-	links, err := n.Handle.LinkList()
+	links, err := n.linkList()
 	if err != nil {
 		return nil, err
 	}
 
 	for _, link := range links {
 		if link.Attrs().HardwareAddr.String() == mac {
-			n.Links[mac] = link
+			n.setLink(mac, link)
 			return link, nil
 		}
 	}
@@ -103,6 +197,142 @@ func (n *NICs) getLink(mac string) (netlink.Link, error) {
 	return nil, fmt.Errorf("link with address %s: %w", mac, nicNotFoundErr)
 }
 
+// SetLinkName renames the link with the given mac address, bringing it down during the rename
+func (n *NICs) SetLinkName(mac string, name string) error {
+	if len(name) == 0 || len(name) > MaxLinkNameLength {
+		return fmt.Errorf("link name %q must be between 1 and %d characters", name, MaxLinkNameLength)
+	}
+	if strings.ContainsAny(name, "/: \t\n") {
+		return fmt.Errorf("link name %q contains invalid characters", name)
+	}
+
+	link, err := n.getLink(mac)
+	if err != nil {
+		return err
+	}
+	if link.Attrs().Name == name {
+		return nil
+	}
+
+	// dhcpcd is bound to the link name, stop it so it can be restarted on the new one
+	_, err = os.Stat(dhcpcdRunFilePrefix + link.Attrs().Name + dhcpcdRunFileSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		cmd := exec.Command("dhcpcd", "-k", link.Attrs().Name)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+	}
+
+	isUp := link.Attrs().Flags&net.FlagUp != 0
+
+	err = netlink.LinkSetDown(link)
+	if err != nil {
+		return err
+	}
+
+	err = netlink.LinkSetName(link, name)
+	if err != nil {
+		return err
+	}
+
+	renamed, err := n.getLink(mac)
+	if err != nil {
+		return err
+	}
+
+	if isUp {
+		return netlink.LinkSetUp(renamed)
+	}
+	return nil
+}
+
+// LinkStatus is the observed state of a link
+type LinkStatus struct {
+	Up              bool
+	Addresses       []*net.IPNet
+	InstalledRoutes int
+}
+
+// GetLinkStatus returns the state of the link with the given mac address, InstalledRoutes is the number of the
+// given routes present on the link
+func (n *NICs) GetLinkStatus(mac string, routes []Route) (LinkStatus, error) {
+	status := LinkStatus{}
+
+	link, err := n.getLink(mac)
+	if err != nil {
+		return status, err
+	}
+	status.Up = link.Attrs().Flags&net.FlagUp != 0
+
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return status, err
+	}
+	for _, addr := range addrs {
+		status.Addresses = append(status.Addresses, addr.IPNet)
+	}
+
+	existingRoutes, err := netlink.RouteList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return status, err
+	}
+	for _, route := range routes {
+		if route.isIn(existingRoutes) {
+			status.InstalledRoutes++
+		}
+	}
+
+	return status, nil
+}
+
+// HasOtherDefaultRoute returns whether an IPv4 default route goes through another link than the one with the given mac address,
+// e.g. through the public interface of a node with a public IP
+func (n *NICs) HasOtherDefaultRoute(mac string) (bool, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return false, err
+	}
+
+	routes, err := netlink.RouteList(nil, netlink.FAMILY_V4)
+	if err != nil {
+		return false, err
+	}
+	return hasDefaultRoute(routes, link.Attrs().Index), nil
+}
+
+// hasDefaultRoute returns whether one of the routes is a default route through another link than linkIndex
+func hasDefaultRoute(routes []netlink.Route, linkIndex int) bool {
+	for _, route := range routes {
+		if route.LinkIndex != linkIndex && routeDst(route) == "0.0.0.0/0" {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *NICs) GetLinkMTU(mac string) (int, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return 0, err
+	}
+	return link.Attrs().MTU, nil
+}
+
+func setMTU(link netlink.Link, mtu int) error {
+	if mtu == 0 {
+		return nil
+	}
+	if mtu < minMTU || mtu > maxMTU {
+		return fmt.Errorf("MTU %d for link %s is not in the allowed range [%d, %d]", mtu, link.Attrs().Name, minMTU, maxMTU)
+	}
+	return netlink.LinkSetMTU(link, mtu)
+}
+
 func maskEqual(m1, m2 net.IPMask) bool {
 	if len(m1) != len(m2) {
 		return false
@@ -116,7 +346,7 @@ func maskEqual(m1, m2 net.IPMask) bool {
 	return true
 }
 
-func (n *NICs) ConfigureDHCPLink(mac string) (string, error) {
+func (n *NICs) ConfigureDHCPLink(mac string, mtu int) (string, error) {
 	link, err := n.getLink(mac)
 	if err != nil {
 		return "", err
@@ -133,6 +363,11 @@ func (n *NICs) ConfigureDHCPLink(mac string) (string, error) {
 		}
 	}
 
+	err = setMTU(link, mtu)
+	if err != nil {
+		return "", err
+	}
+
 	err = netlink.LinkSetUp(link)
 	if err != nil {
 		return "", err
@@ -150,7 +385,7 @@ func (n *NICs) ConfigureDHCPLink(mac string) (string, error) {
 	return addrs[0].IP.String(), nil
 }
 
-func (n *NICs) ConfigureStaticLink(mac string, ip string) error {
+func (n *NICs) ConfigureStaticLink(mac string, ip string, mtu int) error {
 	link, err := n.getLink(mac)
 	if err != nil {
 		return err
@@ -183,6 +418,11 @@ func (n *NICs) ConfigureStaticLink(mac string, ip string) error {
 		}
 	}
 
+	err = setMTU(link, mtu)
+	if err != nil {
+		return err
+	}
+
 	err = netlink.LinkSetUp(link)
 	if err != nil {
 		return err
@@ -0,0 +1,198 @@
+package nics
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestHasDefaultRoute(t *testing.T) {
+	_, private, _ := net.ParseCIDR("172.16.0.0/12")
+	_, ipv4Default, _ := net.ParseCIDR("0.0.0.0/0")
+
+	tests := []struct {
+		name     string
+		routes   []netlink.Route
+		expected bool
+	}{
+		{
+			name: "no route",
+		},
+		{
+			name:     "default route through another link",
+			routes:   []netlink.Route{{LinkIndex: 2, Gw: net.ParseIP("10.0.0.1")}},
+			expected: true,
+		},
+		{
+			name:     "default route with a destination through another link",
+			routes:   []netlink.Route{{LinkIndex: 2, Dst: ipv4Default, Gw: net.ParseIP("10.0.0.1")}},
+			expected: true,
+		},
+		{
+			name:   "default route through the link",
+			routes: []netlink.Route{{LinkIndex: 3, Gw: net.ParseIP("192.168.0.1")}},
+		},
+		{
+			name:   "other route through another link",
+			routes: []netlink.Route{{LinkIndex: 2, Dst: private, Gw: net.ParseIP("10.0.0.1")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasDefaultRoute(tt.routes, 3); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// fakeLinks is a linkLister of the links by index
+type fakeLinks struct {
+	links map[int]netlink.Link
+	err   error
+	lists int
+}
+
+func (f *fakeLinks) LinkList() ([]netlink.Link, error) {
+	f.lists++
+	if f.err != nil {
+		return nil, f.err
+	}
+	links := []netlink.Link{}
+	for _, link := range f.links {
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+func (f *fakeLinks) LinkByIndex(index int) (netlink.Link, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	link, ok := f.links[index]
+	if !ok {
+		return nil, netlink.LinkNotFoundError{}
+	}
+	return link, nil
+}
+
+func TestGetLink(t *testing.T) {
+	const (
+		mac   = "02:00:00:00:00:01"
+		other = "02:00:00:00:00:02"
+	)
+	link := func(index int, name, mac string) netlink.Link {
+		hw, _ := net.ParseMAC(mac)
+		return &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Index: index, Name: name, HardwareAddr: hw}}
+	}
+
+	tests := []struct {
+		name     string
+		cached   netlink.Link
+		links    map[int]netlink.Link
+		err      error
+		expected string
+		lists    int
+		notFound bool
+		failed   bool
+	}{
+		{
+			name:     "unknown link",
+			links:    map[int]netlink.Link{3: link(3, "eth1", mac)},
+			expected: "eth1",
+			lists:    1,
+		},
+		{
+			name:     "known link",
+			cached:   link(3, "eth1", mac),
+			links:    map[int]netlink.Link{3: link(3, "eth1", mac)},
+			expected: "eth1",
+		},
+		{
+			name:     "renamed link",
+			cached:   link(3, "eth1", mac),
+			links:    map[int]netlink.Link{3: link(3, "pn-db", mac)},
+			expected: "pn-db",
+		},
+		{
+			name:     "index reused by another link",
+			cached:   link(3, "eth1", mac),
+			links:    map[int]netlink.Link{3: link(3, "eth1", other), 4: link(4, "eth2", mac)},
+			expected: "eth2",
+			lists:    1,
+		},
+		{
+			name:     "link attached again with another index",
+			cached:   link(3, "eth1", mac),
+			links:    map[int]netlink.Link{5: link(5, "eth3", mac)},
+			expected: "eth3",
+			lists:    1,
+		},
+		{
+			name:     "deleted link",
+			cached:   link(3, "eth1", mac),
+			links:    map[int]netlink.Link{4: link(4, "eth2", other)},
+			lists:    1,
+			notFound: true,
+		},
+		{
+			name:     "index reused, link deleted",
+			cached:   link(3, "eth1", mac),
+			links:    map[int]netlink.Link{3: link(3, "eth1", other)},
+			lists:    1,
+			notFound: true,
+		},
+		{
+			name:   "netlink error",
+			cached: link(3, "eth1", mac),
+			err:    errors.New("netlink error"),
+			failed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lister := &fakeLinks{links: tt.links, err: tt.err}
+			n := &NICs{Links: map[string]netlink.Link{}, lister: lister}
+			if tt.cached != nil {
+				n.Links[mac] = tt.cached
+			}
+
+			got, err := n.getLink(mac)
+			if lister.lists != tt.lists {
+				t.Errorf("expected %d link lists, got %d", tt.lists, lister.lists)
+			}
+			switch {
+			case tt.notFound:
+				if !IsNotFound(err) {
+					t.Errorf("expected a not found error, got %v", err)
+				}
+				if _, ok := n.Links[mac]; ok {
+					t.Errorf("expected the link to be forgotten")
+				}
+				return
+			case tt.failed:
+				if err == nil || IsNotFound(err) {
+					t.Errorf("expected an error, got %v", err)
+				}
+				// the link may still be there, it is kept
+				if n.Links[mac] != tt.cached {
+					t.Errorf("expected the known link to be kept")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got.Attrs().Name != tt.expected {
+				t.Errorf("expected link %s, got %s", tt.expected, got.Attrs().Name)
+			}
+			if n.Links[mac] != got {
+				t.Errorf("expected link %s to be known", tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package nics
+
+import (
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+)
+
+const subscriptionBufferSize = 64
+
+// Update is sent when a link, one of its addresses or one of its routes is updated
+type Update struct {
+	// MAC is the mac address of the link
+	MAC string
+	// Hotplug is true when the link was added, removed, or re-added with another index
+	Hotplug bool
+}
+
+// Subscribe sends an update each time a link, one of its addresses or one of its routes is updated
+// The returned channel is closed when stopCh is closed or when one of the subscriptions fails
+func (n *NICs) Subscribe(stopCh <-chan struct{}) (<-chan Update, error) {
+	done := make(chan struct{})
+
+	linkCh := make(chan netlink.LinkUpdate, subscriptionBufferSize)
+	err := netlink.LinkSubscribe(linkCh, done)
+	if err != nil {
+		close(done)
+		return nil, err
+	}
+
+	addrCh := make(chan netlink.AddrUpdate, subscriptionBufferSize)
+	err = netlink.AddrSubscribe(addrCh, done)
+	if err != nil {
+		close(done)
+		return nil, err
+	}
+
+	routeCh := make(chan netlink.RouteUpdate, subscriptionBufferSize)
+	err = netlink.RouteSubscribe(routeCh, done)
+	if err != nil {
+		close(done)
+		return nil, err
+	}
+
+	updates := make(chan Update, subscriptionBufferSize)
+	go func() {
+		defer close(updates)
+		defer close(done)
+		n.forward(stopCh, linkCh, addrCh, routeCh, macFromIndex, updates)
+	}()
+
+	return updates, nil
+}
+
+// forward sends an update for each link, address or route update of a link with a mac address, looked up with
+// macFromIndex for the addresses and the routes, until stopCh is closed or one of the update channels is closed
+func (n *NICs) forward(stopCh <-chan struct{}, linkCh <-chan netlink.LinkUpdate, addrCh <-chan netlink.AddrUpdate,
+	routeCh <-chan netlink.RouteUpdate, macFromIndex func(int) string, updates chan<- Update) {
+	for {
+		update := Update{}
+		select {
+		case <-stopCh:
+			return
+		case u, ok := <-linkCh:
+			if !ok {
+				return
+			}
+			update.MAC = u.Link.Attrs().HardwareAddr.String()
+			update.Hotplug = u.Header.Type == syscall.RTM_DELLINK || !n.isCurrent(update.MAC, u.Link.Attrs().Index)
+		case u, ok := <-addrCh:
+			if !ok {
+				return
+			}
+			update.MAC = macFromIndex(u.LinkIndex)
+		case u, ok := <-routeCh:
+			if !ok {
+				return
+			}
+			update.MAC = macFromIndex(u.LinkIndex)
+		}
+
+		if update.MAC == "" {
+			continue
+		}
+
+		select {
+		case updates <- update:
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// isCurrent returns whether the known link with the given mac address has the given index
+func (n *NICs) isCurrent(mac string, index int) bool {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	link, ok := n.Links[mac]
+	return ok && link.Attrs().Index == index
+}
+
+func macFromIndex(index int) string {
+	if index == 0 {
+		return ""
+	}
+	// use a dedicated netlink socket, the handle is used by the reconciler
+	link, err := netlink.LinkByIndex(index)
+	if err != nil {
+		return ""
+	}
+	return link.Attrs().HardwareAddr.String()
+}
@@ -0,0 +1,142 @@
+package nics
+
+import (
+	"net"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func dummyLink(index int, mac string) netlink.Link {
+	hw, _ := net.ParseMAC(mac)
+	return &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Index: index, Name: "eth" + mac[len(mac)-1:], HardwareAddr: hw}}
+}
+
+func TestForward(t *testing.T) {
+	const (
+		known   = "02:00:00:00:00:01"
+		unknown = "02:00:00:00:00:02"
+	)
+	n := &NICs{Links: map[string]netlink.Link{known: dummyLink(3, known)}}
+	macFromIndex := func(index int) string {
+		switch index {
+		case 3:
+			return known
+		case 4:
+			return unknown
+		}
+		return ""
+	}
+
+	deleted := netlink.LinkUpdate{Link: dummyLink(3, known)}
+	deleted.Header.Type = syscall.RTM_DELLINK
+
+	tests := []struct {
+		name     string
+		link     []netlink.LinkUpdate
+		addr     []netlink.AddrUpdate
+		route    []netlink.RouteUpdate
+		expected []Update
+	}{
+		{
+			name:     "known link updated",
+			link:     []netlink.LinkUpdate{{Link: dummyLink(3, known)}},
+			expected: []Update{{MAC: known}},
+		},
+		{
+			name:     "known link deleted",
+			link:     []netlink.LinkUpdate{deleted},
+			expected: []Update{{MAC: known, Hotplug: true}},
+		},
+		{
+			name:     "known link with another index",
+			link:     []netlink.LinkUpdate{{Link: dummyLink(5, known)}},
+			expected: []Update{{MAC: known, Hotplug: true}},
+		},
+		{
+			name:     "unknown link added",
+			link:     []netlink.LinkUpdate{{Link: dummyLink(4, unknown)}},
+			expected: []Update{{MAC: unknown, Hotplug: true}},
+		},
+		{
+			name:     "link without mac address",
+			link:     []netlink.LinkUpdate{{Link: &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Index: 1, Name: "lo"}}}},
+			expected: []Update{},
+		},
+		{
+			name:     "address of a link",
+			addr:     []netlink.AddrUpdate{{LinkIndex: 3}, {LinkIndex: 4}},
+			expected: []Update{{MAC: known}, {MAC: unknown}},
+		},
+		{
+			name:     "address of a missing link",
+			addr:     []netlink.AddrUpdate{{LinkIndex: 6}, {LinkIndex: 0}},
+			expected: []Update{},
+		},
+		{
+			name:     "route of a link",
+			route:    []netlink.RouteUpdate{{Route: netlink.Route{LinkIndex: 3}}},
+			expected: []Update{{MAC: known}},
+		},
+		{
+			name:     "route without link",
+			route:    []netlink.RouteUpdate{{Route: netlink.Route{}}},
+			expected: []Update{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// forward returns when the channel with the updates is closed, after having read them,
+			// the other channels are left empty and open
+			linkCh := make(chan netlink.LinkUpdate, len(tt.link))
+			for _, u := range tt.link {
+				linkCh <- u
+			}
+			addrCh := make(chan netlink.AddrUpdate, len(tt.addr))
+			for _, u := range tt.addr {
+				addrCh <- u
+			}
+			routeCh := make(chan netlink.RouteUpdate, len(tt.route))
+			for _, u := range tt.route {
+				routeCh <- u
+			}
+			switch {
+			case len(tt.link) != 0:
+				close(linkCh)
+			case len(tt.addr) != 0:
+				close(addrCh)
+			default:
+				close(routeCh)
+			}
+
+			updates := make(chan Update, subscriptionBufferSize)
+			n.forward(make(chan struct{}), linkCh, addrCh, routeCh, macFromIndex, updates)
+			close(updates)
+
+			got := []Update{}
+			for u := range updates {
+				got = append(got, u)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestForwardStop(t *testing.T) {
+	stopCh := make(chan struct{})
+	close(stopCh)
+	// nothing reads the updates, forward must return instead of blocking
+	updates := make(chan Update)
+	addrCh := make(chan netlink.AddrUpdate, 1)
+	addrCh <- netlink.AddrUpdate{LinkIndex: 3}
+
+	n := &NICs{Links: map[string]netlink.Link{}}
+	n.forward(stopCh, make(chan netlink.LinkUpdate), addrCh, make(chan netlink.RouteUpdate), func(int) string {
+		return "02:00:00:00:00:01"
+	}, updates)
+}
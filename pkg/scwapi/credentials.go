@@ -0,0 +1,38 @@
+package scwapi
+
+import (
+	"strings"
+
+	"github.com/scaleway/scaleway-sdk-go/scw"
+)
+
+// ProfileKeys are the keys read by ProfileFromData
+var ProfileKeys = []string{
+	scw.ScwAccessKeyEnv,
+	scw.ScwSecretKeyEnv,
+	scw.ScwDefaultOrganizationIDEnv,
+	scw.ScwDefaultProjectIDEnv,
+	scw.ScwDefaultRegionEnv,
+	scw.ScwDefaultZoneEnv,
+}
+
+// ProfileFromData returns the profile described by data, using the keys of the environment variables, e.g. SCW_ACCESS_KEY
+// Missing or empty keys are left unset
+func ProfileFromData(data map[string][]byte) *scw.Profile {
+	get := func(key string) *string {
+		value := strings.TrimSpace(string(data[key]))
+		if value == "" {
+			return nil
+		}
+		return &value
+	}
+
+	return &scw.Profile{
+		AccessKey:             get(scw.ScwAccessKeyEnv),
+		SecretKey:             get(scw.ScwSecretKeyEnv),
+		DefaultOrganizationID: get(scw.ScwDefaultOrganizationIDEnv),
+		DefaultProjectID:      get(scw.ScwDefaultProjectIDEnv),
+		DefaultRegion:         get(scw.ScwDefaultRegionEnv),
+		DefaultZone:           get(scw.ScwDefaultZoneEnv),
+	}
+}
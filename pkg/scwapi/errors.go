@@ -0,0 +1,54 @@
+package scwapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/scaleway/scaleway-sdk-go/scw"
+)
+
+// ErrorClass is the class of an error returned by the Scaleway API
+type ErrorClass string
+
+const (
+	// ErrorClassTransient is an error expected to go away by itself, e.g. a 5xx, a timeout or a resource in a transient state
+	ErrorClassTransient ErrorClass = "Transient"
+	// ErrorClassQuota is an exceeded quota, a throttled request or an out of stock resource
+	ErrorClassQuota ErrorClass = "Quota"
+	// ErrorClassNotFound is a resource not found
+	ErrorClassNotFound ErrorClass = "NotFound"
+	// ErrorClassPermission is a denied authentication or an insufficient permission
+	ErrorClassPermission ErrorClass = "Permission"
+)
+
+// Classify returns the class of err, any error not identified is transient
+func Classify(err error) ErrorClass {
+	var quotaErr *scw.QuotasExceededError
+	var outOfStockErr *scw.OutOfStockError
+	var permissionErr *scw.PermissionsDeniedError
+	var authenticationErr *scw.DeniedAuthenticationError
+	var notFoundErr *scw.ResourceNotFoundError
+	var transientErr *scw.TransientStateError
+	var respErr *scw.ResponseError
+
+	switch {
+	case errors.As(err, &quotaErr), errors.As(err, &outOfStockErr):
+		return ErrorClassQuota
+	case errors.As(err, &permissionErr), errors.As(err, &authenticationErr):
+		return ErrorClassPermission
+	case errors.As(err, &notFoundErr):
+		return ErrorClassNotFound
+	case errors.As(err, &transientErr):
+		return ErrorClassTransient
+	case errors.As(err, &respErr):
+		switch respErr.StatusCode {
+		case http.StatusTooManyRequests:
+			return ErrorClassQuota
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return ErrorClassPermission
+		case http.StatusNotFound:
+			return ErrorClassNotFound
+		}
+	}
+	return ErrorClassTransient
+}
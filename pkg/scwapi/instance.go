@@ -0,0 +1,81 @@
+package scwapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/scaleway/scaleway-sdk-go/scw"
+)
+
+// PrivateNIC is a private NIC of a server, with the tags the instance package of the SDK doesn't decode yet
+type PrivateNIC struct {
+	ID               string   `json:"id"`
+	ServerID         string   `json:"server_id"`
+	PrivateNetworkID string   `json:"private_network_id"`
+	MacAddress       string   `json:"mac_address"`
+	Tags             []string `json:"tags"`
+}
+
+// HasTag returns whether the private NIC has the tag
+func (p *PrivateNIC) HasTag(tag string) bool {
+	for _, t := range p.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// ListPrivateNICs returns the private NICs of the server
+func ListPrivateNICs(client *scw.Client, zone scw.Zone, serverID string) ([]*PrivateNIC, error) {
+	zone, err := defaultZone(client, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		PrivateNICs []*PrivateNIC `json:"private_nics"`
+	}
+	err = client.Do(&scw.ScalewayRequest{
+		Method:  http.MethodGet,
+		Path:    fmt.Sprintf("/instance/v1/zones/%s/servers/%s/private_nics", zone, serverID),
+		Headers: http.Header{},
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.PrivateNICs, nil
+}
+
+// CreatePrivateNIC attaches the server to the private network with a private NIC tagged with tags
+func CreatePrivateNIC(client *scw.Client, zone scw.Zone, serverID, privateNetworkID string, tags []string) (*PrivateNIC, error) {
+	zone, err := defaultZone(client, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &scw.ScalewayRequest{
+		Method:  http.MethodPost,
+		Path:    fmt.Sprintf("/instance/v1/zones/%s/servers/%s/private_nics", zone, serverID),
+		Headers: http.Header{},
+	}
+	err = req.SetBody(struct {
+		PrivateNetworkID string   `json:"private_network_id"`
+		Tags             []string `json:"tags,omitempty"`
+	}{
+		PrivateNetworkID: privateNetworkID,
+		Tags:             tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		PrivateNIC *PrivateNIC `json:"private_nic"`
+	}
+	err = client.Do(req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.PrivateNIC, nil
+}
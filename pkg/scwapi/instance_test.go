@@ -0,0 +1,81 @@
+package scwapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestListPrivateNICs(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/instance/v1/zones/fr-par-1/servers/server/private_nics" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]interface{}{
+			"private_nics": []map[string]interface{}{
+				{"id": "pnic", "server_id": "server", "private_network_id": "pn", "mac_address": "02:00:00:00:00:01", "tags": []string{"owner"}},
+			},
+		})
+	})
+
+	pnics, err := ListPrivateNICs(client, "", "server")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []*PrivateNIC{
+		{ID: "pnic", ServerID: "server", PrivateNetworkID: "pn", MacAddress: "02:00:00:00:00:01", Tags: []string{"owner"}},
+	}
+	if !reflect.DeepEqual(pnics, expected) {
+		t.Errorf("expected %+v, got %+v", expected, pnics)
+	}
+	if !pnics[0].HasTag("owner") || pnics[0].HasTag("other") {
+		t.Errorf("expected only the owner tag, got %v", pnics[0].Tags)
+	}
+}
+
+func TestCreatePrivateNIC(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     []string
+		expected map[string]interface{}
+	}{
+		{
+			name:     "with tags",
+			tags:     []string{"owner", "cluster"},
+			expected: map[string]interface{}{"private_network_id": "pn", "tags": []interface{}{"owner", "cluster"}},
+		},
+		{
+			name:     "without tags",
+			expected: map[string]interface{}{"private_network_id": "pn"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/instance/v1/zones/fr-par-1/servers/server/private_nics" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+				body := map[string]interface{}{}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Fatalf("could not decode request: %s", err)
+				}
+				if !reflect.DeepEqual(body, tt.expected) {
+					t.Errorf("expected body %v, got %v", tt.expected, body)
+				}
+				writeJSON(t, w, http.StatusCreated, map[string]interface{}{
+					"private_nic": map[string]interface{}{"id": "pnic", "server_id": "server", "private_network_id": "pn", "tags": tt.tags},
+				})
+			})
+
+			pnic, err := CreatePrivateNIC(client, "", "server", "pn", tt.tags)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if pnic.ID != "pnic" || !reflect.DeepEqual(pnic.Tags, tt.tags) {
+				t.Errorf("expected private NIC pnic with tags %v, got %+v", tt.tags, pnic)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package scwapi
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultQPS is the default number of requests per second allowed to the Scaleway API
+	DefaultQPS = 10
+	// DefaultBurst is the default burst of requests allowed to the Scaleway API
+	DefaultBurst = 20
+	// DefaultMaxRetries is the default number of retries of a throttled or failed request
+	DefaultMaxRetries = 3
+
+	// minRetryDelay is the delay before the first retry, doubled on each retry
+	minRetryDelay = time.Millisecond * 500
+	// maxRetryDelay is the longest delay the transport waits before a retry, longer Retry-After are left to the caller
+	maxRetryDelay = time.Second * 30
+)
+
+// Transport is an http.RoundTripper limiting the rate of the requests to the Scaleway API
+// Throttled requests, and idempotent requests failing with a 5xx, are retried with an exponential backoff,
+// respecting the Retry-After header sent by the API, which also pauses all the other requests
+type Transport struct {
+	Next       http.RoundTripper
+	Limiter    *rate.Limiter
+	MaxRetries int
+
+	lock       sync.Mutex
+	retryAfter time.Time
+}
+
+// NewTransport returns a Transport wrapping next, or http.DefaultTransport if nil
+func NewTransport(next http.RoundTripper, qps float64, burst int, maxRetries int) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{
+		Next:       next,
+		Limiter:    rate.NewLimiter(rate.Limit(qps), burst),
+		MaxRetries: maxRetries,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := t.wait(req); err != nil {
+			return nil, err
+		}
+
+		resp, err := t.Next.RoundTrip(req)
+		if err != nil || !shouldRetry(req, resp) {
+			return resp, err
+		}
+
+		delay := backoff(attempt)
+		if retryAfter, ok := parseRetryAfter(resp); ok {
+			delay = retryAfter
+			t.pause(retryAfter)
+		}
+		if attempt >= t.MaxRetries || delay > maxRetryDelay || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+
+		// the response is dropped, drain it so that the connection can be reused
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			// a RoundTripper must not modify the request, retry with a copy
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// RetryAfter returns how long the requests are paused because of a Retry-After sent by the API
+func (t *Transport) RetryAfter() time.Duration {
+	if t == nil {
+		return 0
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return time.Until(t.retryAfter)
+}
+
+// pause delays all the requests for d
+func (t *Transport) pause(d time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if until := time.Now().Add(d); until.After(t.retryAfter) {
+		t.retryAfter = until
+	}
+}
+
+// wait blocks until the request is allowed by the Retry-After and the limiter
+func (t *Transport) wait(req *http.Request) error {
+	if d := t.RetryAfter(); d > 0 {
+		timer := time.NewTimer(d)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return req.Context().Err()
+		case <-timer.C:
+		}
+	}
+	return t.Limiter.Wait(req.Context())
+}
+
+// shouldRetry returns whether the request can be retried given its response
+func shouldRetry(req *http.Request, resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return isIdempotent(req.Method)
+	}
+	return false
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before the retry following attempt
+func backoff(attempt int) time.Duration {
+	delay := minRetryDelay << uint(attempt)
+	if delay > maxRetryDelay || delay <= 0 {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+// parseRetryAfter returns the delay of the Retry-After header of the response, in seconds or as an HTTP date
+func parseRetryAfter(resp *http.Response) (time.Duration, bool) {
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		d := time.Until(date)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
@@ -0,0 +1,217 @@
+package scwapi
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// scriptedTransport answers the requests with the statuses in order, the last one being repeated
+type scriptedTransport struct {
+	statuses   []int
+	retryAfter string
+	bodies     []string
+}
+
+func (s *scriptedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := ""
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = string(b)
+	}
+	s.bodies = append(s.bodies, body)
+
+	status := s.statuses[len(s.statuses)-1]
+	if len(s.bodies) <= len(s.statuses) {
+		status = s.statuses[len(s.bodies)-1]
+	}
+	header := http.Header{}
+	if s.retryAfter != "" {
+		header.Set("Retry-After", s.retryAfter)
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func TestTransportRoundTrip(t *testing.T) {
+	tests := []struct {
+		name             string
+		method           string
+		statuses         []int
+		retryAfter       string
+		expectedStatus   int
+		expectedAttempts int
+		paused           bool
+	}{
+		{
+			name:             "success",
+			method:           http.MethodGet,
+			statuses:         []int{http.StatusOK},
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "throttled",
+			method:           http.MethodPost,
+			statuses:         []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:       "0",
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "idempotent request failing",
+			method:           http.MethodGet,
+			statuses:         []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			retryAfter:       "0",
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "non idempotent request failing",
+			method:           http.MethodPost,
+			statuses:         []int{http.StatusServiceUnavailable, http.StatusOK},
+			retryAfter:       "0",
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "retries exhausted",
+			method:           http.MethodGet,
+			statuses:         []int{http.StatusTooManyRequests},
+			retryAfter:       "0",
+			expectedStatus:   http.StatusTooManyRequests,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "retry after too long",
+			method:           http.MethodGet,
+			statuses:         []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:       "60",
+			expectedStatus:   http.StatusTooManyRequests,
+			expectedAttempts: 1,
+			paused:           true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &scriptedTransport{statuses: tt.statuses, retryAfter: tt.retryAfter}
+			transport := &Transport{
+				Next:       next,
+				Limiter:    rate.NewLimiter(rate.Inf, 0),
+				MaxRetries: 2,
+			}
+
+			req, err := http.NewRequest(tt.method, "https://api.scaleway.com/", bytes.NewReader([]byte("body")))
+			if err != nil {
+				t.Fatalf("could not create request: %s", err)
+			}
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if len(next.bodies) != tt.expectedAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.expectedAttempts, len(next.bodies))
+			}
+			for i, body := range next.bodies {
+				if body != "body" {
+					t.Errorf("expected the body to be sent on attempt %d, got %q", i, body)
+				}
+			}
+			if paused := transport.RetryAfter() > 0; paused != tt.paused {
+				t.Errorf("expected paused %v, got %v", tt.paused, paused)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected time.Duration
+		ok       bool
+	}{
+		{
+			name: "missing",
+		},
+		{
+			name:     "seconds",
+			header:   "3",
+			expected: time.Second * 3,
+			ok:       true,
+		},
+		{
+			name:     "date",
+			header:   time.Now().Add(time.Minute).UTC().Format(http.TimeFormat),
+			expected: time.Minute,
+			ok:       true,
+		},
+		{
+			name:   "past date",
+			header: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat),
+			ok:     true,
+		},
+		{
+			name:   "negative seconds",
+			header: "-1",
+		},
+		{
+			name:   "invalid",
+			header: "soon",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.header != "" {
+				resp.Header.Set("Retry-After", tt.header)
+			}
+			d, ok := parseRetryAfter(resp)
+			if ok != tt.ok {
+				t.Fatalf("expected ok %v, got %v", tt.ok, ok)
+			}
+			// the dates have a precision of a second
+			if d > tt.expected || d < tt.expected-time.Second {
+				t.Errorf("expected %s, got %s", tt.expected, d)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{attempt: 0, expected: minRetryDelay},
+		{attempt: 1, expected: minRetryDelay * 2},
+		{attempt: 3, expected: minRetryDelay * 8},
+		{attempt: 10, expected: maxRetryDelay},
+		{attempt: 100, expected: maxRetryDelay},
+	}
+
+	for _, tt := range tests {
+		if d := backoff(tt.attempt); d != tt.expected {
+			t.Errorf("expected %s for attempt %d, got %s", tt.expected, tt.attempt, d)
+		}
+	}
+}
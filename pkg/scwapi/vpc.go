@@ -0,0 +1,42 @@
+package scwapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/scaleway/scaleway-sdk-go/scw"
+)
+
+// defaultZone returns zone, or the default zone of the client if empty
+func defaultZone(client *scw.Client, zone scw.Zone) (scw.Zone, error) {
+	if zone == "" {
+		zone, _ = client.GetDefaultZone()
+	}
+	if zone == "" {
+		return "", errors.New("field Zone cannot be empty in request")
+	}
+	return zone, nil
+}
+
+// PrivateNetworkSubnets returns the subnets of a private network
+// The vpc package of the SDK doesn't decode them yet, so the private network is read with a raw request
+func PrivateNetworkSubnets(client *scw.Client, zone scw.Zone, privateNetworkID string) ([]string, error) {
+	zone, err := defaultZone(client, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Subnets []string `json:"subnets"`
+	}
+	err = client.Do(&scw.ScalewayRequest{
+		Method:  http.MethodGet,
+		Path:    fmt.Sprintf("/vpc/v1/zones/%s/private-networks/%s", zone, privateNetworkID),
+		Headers: http.Header{},
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Subnets, nil
+}
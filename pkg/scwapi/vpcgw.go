@@ -0,0 +1,154 @@
+package scwapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/scaleway/scaleway-sdk-go/scw"
+)
+
+// DHCPEntryTypeReservation is the type of the static DHCP entries
+const DHCPEntryTypeReservation = "reservation"
+
+// GatewayNetwork is the connection of a Public Gateway to a private network
+type GatewayNetwork struct {
+	ID               string `json:"id"`
+	GatewayID        string `json:"gateway_id"`
+	PrivateNetworkID string `json:"private_network_id"`
+	EnableDHCP       bool   `json:"enable_dhcp"`
+	// Address is the address of the Public Gateway in the private network, with its prefix length
+	// It is empty when DHCP is enabled, the Public Gateway having the address of the DHCP server
+	Address string              `json:"address"`
+	DHCP    *GatewayNetworkDHCP `json:"dhcp"`
+}
+
+// GatewayNetworkDHCP is the DHCP server of a GatewayNetwork
+type GatewayNetworkDHCP struct {
+	ID string `json:"id"`
+	// Address is the address of the DHCP server in the private network
+	Address string `json:"address"`
+}
+
+// DHCPEntry is a lease or a reservation of the DHCP server of a GatewayNetwork
+type DHCPEntry struct {
+	ID               string `json:"id"`
+	GatewayNetworkID string `json:"gateway_network_id"`
+	MacAddress       string `json:"mac_address"`
+	IPAddress        string `json:"ip_address"`
+	Type             string `json:"type"`
+}
+
+// VpcGwAPI is a client of the Public Gateway API, which the SDK doesn't provide yet
+// It only covers what is needed by the controller
+type VpcGwAPI struct {
+	client *scw.Client
+}
+
+// NewVpcGwAPI returns a VpcGwAPI using client
+func NewVpcGwAPI(client *scw.Client) *VpcGwAPI {
+	return &VpcGwAPI{
+		client: client,
+	}
+}
+
+// ListGatewayNetworks returns the GatewayNetworks of the private network, at most 100
+func (a *VpcGwAPI) ListGatewayNetworks(zone scw.Zone, privateNetworkID string) ([]*GatewayNetwork, error) {
+	zone, err := defaultZone(a.client, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("private_network_id", privateNetworkID)
+	query.Set("page_size", "100")
+
+	var resp struct {
+		GatewayNetworks []*GatewayNetwork `json:"gateway_networks"`
+	}
+	err = a.client.Do(&scw.ScalewayRequest{
+		Method:  http.MethodGet,
+		Path:    fmt.Sprintf("/vpc-gw/v1/zones/%s/gateway-networks", zone),
+		Query:   query,
+		Headers: http.Header{},
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.GatewayNetworks, nil
+}
+
+// ListDHCPEntries returns the reservations of the GatewayNetwork for the mac address, at most 100
+func (a *VpcGwAPI) ListDHCPEntries(zone scw.Zone, gatewayNetworkID, macAddress string) ([]*DHCPEntry, error) {
+	zone, err := defaultZone(a.client, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("gateway_network_id", gatewayNetworkID)
+	query.Set("mac_address", macAddress)
+	query.Set("type", DHCPEntryTypeReservation)
+	query.Set("page_size", "100")
+
+	var resp struct {
+		DHCPEntries []*DHCPEntry `json:"dhcp_entries"`
+	}
+	err = a.client.Do(&scw.ScalewayRequest{
+		Method:  http.MethodGet,
+		Path:    fmt.Sprintf("/vpc-gw/v1/zones/%s/dhcp-entries", zone),
+		Query:   query,
+		Headers: http.Header{},
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.DHCPEntries, nil
+}
+
+// CreateDHCPEntry reserves the IP address for the mac address in the DHCP server of the GatewayNetwork
+func (a *VpcGwAPI) CreateDHCPEntry(zone scw.Zone, gatewayNetworkID, macAddress, ipAddress string) (*DHCPEntry, error) {
+	zone, err := defaultZone(a.client, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &scw.ScalewayRequest{
+		Method:  http.MethodPost,
+		Path:    fmt.Sprintf("/vpc-gw/v1/zones/%s/dhcp-entries", zone),
+		Headers: http.Header{},
+	}
+	err = req.SetBody(struct {
+		GatewayNetworkID string `json:"gateway_network_id"`
+		MacAddress       string `json:"mac_address"`
+		IPAddress        string `json:"ip_address"`
+	}{
+		GatewayNetworkID: gatewayNetworkID,
+		MacAddress:       macAddress,
+		IPAddress:        ipAddress,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp DHCPEntry
+	err = a.client.Do(req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// DeleteDHCPEntry deletes the DHCP entry
+func (a *VpcGwAPI) DeleteDHCPEntry(zone scw.Zone, dhcpEntryID string) error {
+	zone, err := defaultZone(a.client, zone)
+	if err != nil {
+		return err
+	}
+
+	return a.client.Do(&scw.ScalewayRequest{
+		Method:  http.MethodDelete,
+		Path:    fmt.Sprintf("/vpc-gw/v1/zones/%s/dhcp-entries/%s", zone, dhcpEntryID),
+		Headers: http.Header{},
+	}, nil)
+}
@@ -0,0 +1,183 @@
+package scwapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/scaleway/scaleway-sdk-go/scw"
+)
+
+// newTestClient returns a client sending its requests to handler
+func newTestClient(t *testing.T, handler http.HandlerFunc) *scw.Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := scw.NewClient(
+		scw.WithoutAuth(),
+		scw.WithAPIURL(server.URL),
+		scw.WithDefaultZone(scw.ZoneFrPar1),
+	)
+	if err != nil {
+		t.Fatalf("could not create client: %s", err)
+	}
+	return client
+}
+
+// newTestVpcGwAPI returns a VpcGwAPI sending its requests to handler
+func newTestVpcGwAPI(t *testing.T, handler http.HandlerFunc) *VpcGwAPI {
+	return NewVpcGwAPI(newTestClient(t, handler))
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		t.Errorf("could not encode response: %s", err)
+	}
+}
+
+func TestListGatewayNetworks(t *testing.T) {
+	api := newTestVpcGwAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/vpc-gw/v1/zones/fr-par-1/gateway-networks" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if id := r.URL.Query().Get("private_network_id"); id != "pn" {
+			t.Errorf("expected private_network_id pn, got %q", id)
+		}
+		writeJSON(t, w, http.StatusOK, map[string]interface{}{
+			"gateway_networks": []map[string]interface{}{
+				{"id": "static", "gateway_id": "gw", "private_network_id": "pn", "address": "192.168.0.1/24"},
+				{"id": "dhcp", "gateway_id": "gw", "private_network_id": "pn", "enable_dhcp": true, "dhcp": map[string]string{"id": "dhcp-server", "address": "192.168.1.1"}},
+			},
+		})
+	})
+
+	gatewayNetworks, err := api.ListGatewayNetworks("", "pn")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []*GatewayNetwork{
+		{ID: "static", GatewayID: "gw", PrivateNetworkID: "pn", Address: "192.168.0.1/24"},
+		{ID: "dhcp", GatewayID: "gw", PrivateNetworkID: "pn", EnableDHCP: true, DHCP: &GatewayNetworkDHCP{ID: "dhcp-server", Address: "192.168.1.1"}},
+	}
+	if !reflect.DeepEqual(gatewayNetworks, expected) {
+		t.Errorf("expected %+v, got %+v", expected, gatewayNetworks)
+	}
+}
+
+func TestListDHCPEntries(t *testing.T) {
+	api := newTestVpcGwAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/vpc-gw/v1/zones/nl-ams-1/dhcp-entries" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		query := r.URL.Query()
+		for key, value := range map[string]string{
+			"gateway_network_id": "gwn",
+			"mac_address":        "02:00:00:00:00:01",
+			"type":               DHCPEntryTypeReservation,
+		} {
+			if query.Get(key) != value {
+				t.Errorf("expected %s %s, got %q", key, value, query.Get(key))
+			}
+		}
+		writeJSON(t, w, http.StatusOK, map[string]interface{}{
+			"dhcp_entries": []map[string]string{
+				{"id": "entry", "gateway_network_id": "gwn", "mac_address": "02:00:00:00:00:01", "ip_address": "192.168.1.10", "type": DHCPEntryTypeReservation},
+			},
+		})
+	})
+
+	entries, err := api.ListDHCPEntries(scw.ZoneNlAms1, "gwn", "02:00:00:00:00:01")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []*DHCPEntry{
+		{ID: "entry", GatewayNetworkID: "gwn", MacAddress: "02:00:00:00:00:01", IPAddress: "192.168.1.10", Type: DHCPEntryTypeReservation},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %+v, got %+v", expected, entries)
+	}
+}
+
+func TestCreateDHCPEntry(t *testing.T) {
+	api := newTestVpcGwAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/vpc-gw/v1/zones/fr-par-1/dhcp-entries" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		body := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("could not decode request: %s", err)
+		}
+		expected := map[string]string{"gateway_network_id": "gwn", "mac_address": "02:00:00:00:00:01", "ip_address": "192.168.1.10"}
+		if !reflect.DeepEqual(body, expected) {
+			t.Errorf("expected body %v, got %v", expected, body)
+		}
+		body["id"] = "entry"
+		body["type"] = DHCPEntryTypeReservation
+		writeJSON(t, w, http.StatusOK, body)
+	})
+
+	entry, err := api.CreateDHCPEntry("", "gwn", "02:00:00:00:00:01", "192.168.1.10")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := &DHCPEntry{ID: "entry", GatewayNetworkID: "gwn", MacAddress: "02:00:00:00:00:01", IPAddress: "192.168.1.10", Type: DHCPEntryTypeReservation}
+	if !reflect.DeepEqual(entry, expected) {
+		t.Errorf("expected %+v, got %+v", expected, entry)
+	}
+}
+
+func TestDeleteDHCPEntry(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		expected ErrorClass
+	}{
+		{
+			name:   "deleted",
+			status: http.StatusNoContent,
+		},
+		{
+			name:     "not found",
+			status:   http.StatusNotFound,
+			expected: ErrorClassNotFound,
+		},
+		{
+			name:     "forbidden",
+			status:   http.StatusForbidden,
+			expected: ErrorClassPermission,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestVpcGwAPI(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodDelete || r.URL.Path != "/vpc-gw/v1/zones/fr-par-1/dhcp-entries/entry" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+				if tt.status == http.StatusNoContent {
+					w.WriteHeader(tt.status)
+					return
+				}
+				writeJSON(t, w, tt.status, map[string]string{"message": http.StatusText(tt.status)})
+			})
+
+			err := api.DeleteDHCPEntry("", "entry")
+			if tt.expected == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if class := Classify(err); class != tt.expected {
+				t.Errorf("expected error class %s, got %s", tt.expected, class)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package scwmetrics
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	metricsNamespace = "scaleway_k8s_vpc"
+	metricsSubsystem = "scaleway_api"
+
+	// transportErrorCode is used as code when no response was received
+	transportErrorCode = "error"
+)
+
+var (
+	uuidRegexp = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "requests_total",
+		Help:      "Number of requests to the Scaleway API per endpoint, zone and status code",
+	}, []string{"method", "endpoint", "zone", "code"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the requests to the Scaleway API per endpoint and zone",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "endpoint", "zone"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(requestsTotal, requestDuration)
+}
+
+// Transport is an http.RoundTripper recording metrics about the requests to the Scaleway API
+type Transport struct {
+	Next http.RoundTripper
+}
+
+// NewTransport returns a Transport wrapping next, or http.DefaultTransport if nil
+func NewTransport(next http.RoundTripper) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{
+		Next: next,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint, zone := endpointFromPath(req.URL.Path)
+
+	start := time.Now()
+	resp, err := t.Next.RoundTrip(req)
+	requestDuration.WithLabelValues(req.Method, endpoint, zone).Observe(time.Since(start).Seconds())
+
+	code := transportErrorCode
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	requestsTotal.WithLabelValues(req.Method, endpoint, zone, code).Inc()
+
+	return resp, err
+}
+
+// endpointFromPath returns the path with the zone or region and the IDs replaced, and the zone or region
+// e.g. /instance/v1/zones/fr-par-1/servers/<id> gives /instance/v1/zones/{zone}/servers/{id} and fr-par-1
+func endpointFromPath(path string) (string, string) {
+	zone := ""
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if i > 0 && (segments[i-1] == "zones" || segments[i-1] == "regions") {
+			zone = segment
+			segments[i] = "{" + strings.TrimSuffix(segments[i-1], "s") + "}"
+			continue
+		}
+		if uuidRegexp.MatchString(segment) {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/"), zone
+}
@@ -0,0 +1,108 @@
+package scwmetrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestEndpointFromPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		endpoint string
+		zone     string
+	}{
+		{
+			path:     "/instance/v1/zones/fr-par-1/servers",
+			endpoint: "/instance/v1/zones/{zone}/servers",
+			zone:     "fr-par-1",
+		},
+		{
+			path:     "/instance/v1/zones/fr-par-1/servers/0f6e2c51-3b1c-4a4e-9b1e-2f4a4e0c4d11",
+			endpoint: "/instance/v1/zones/{zone}/servers/{id}",
+			zone:     "fr-par-1",
+		},
+		{
+			path:     "/instance/v1/zones/nl-ams-1/servers/0f6e2c51-3b1c-4a4e-9b1e-2f4a4e0c4d11/private_nics/7a2f0b13-5c4d-4e6f-8a9b-0c1d2e3f4a5b",
+			endpoint: "/instance/v1/zones/{zone}/servers/{id}/private_nics/{id}",
+			zone:     "nl-ams-1",
+		},
+		{
+			path:     "/vpc/v1/zones/fr-par-1/private-networks/0f6e2c51-3b1c-4a4e-9b1e-2f4a4e0c4d11",
+			endpoint: "/vpc/v1/zones/{zone}/private-networks/{id}",
+			zone:     "fr-par-1",
+		},
+		{
+			path:     "/vpc-gw/v1/regions/fr-par/gateways",
+			endpoint: "/vpc-gw/v1/regions/{region}/gateways",
+			zone:     "fr-par",
+		},
+		{
+			// only the lowercase UUIDs of the API are collapsed
+			path:     "/instance/v1/zones/fr-par-1/servers/0F6E2C51-3B1C-4A4E-9B1E-2F4A4E0C4D11",
+			endpoint: "/instance/v1/zones/{zone}/servers/0F6E2C51-3B1C-4A4E-9B1E-2F4A4E0C4D11",
+			zone:     "fr-par-1",
+		},
+		{
+			path:     "/instance/v1/zones/fr-par-1/servers/not-an-id",
+			endpoint: "/instance/v1/zones/{zone}/servers/not-an-id",
+			zone:     "fr-par-1",
+		},
+		{
+			path:     "/account/v2alpha1/ssh-keys",
+			endpoint: "/account/v2alpha1/ssh-keys",
+		},
+		{
+			path:     "/",
+			endpoint: "/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			endpoint, zone := endpointFromPath(tt.path)
+			if endpoint != tt.endpoint || zone != tt.zone {
+				t.Errorf("expected %s in %q, got %s in %q", tt.endpoint, tt.zone, endpoint, zone)
+			}
+		})
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransport(t *testing.T) {
+	transport := NewTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodDelete {
+			return nil, errors.New("connection reset")
+		}
+		recorder := httptest.NewRecorder()
+		recorder.WriteHeader(http.StatusNotFound)
+		return recorder.Result(), nil
+	}))
+	const endpoint = "/instance/v1/zones/{zone}/servers/{id}"
+	notFound := requestsTotal.WithLabelValues(http.MethodGet, endpoint, "fr-par-1", "404")
+	failed := requestsTotal.WithLabelValues(http.MethodDelete, endpoint, "fr-par-1", transportErrorCode)
+	notFoundBefore, failedBefore := testutil.ToFloat64(notFound), testutil.ToFloat64(failed)
+
+	for _, method := range []string{http.MethodGet, http.MethodGet, http.MethodDelete} {
+		req := httptest.NewRequest(method, "https://api.scaleway.com/instance/v1/zones/fr-par-1/servers/0f6e2c51-3b1c-4a4e-9b1e-2f4a4e0c4d11", nil)
+		resp, err := transport.RoundTrip(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+
+	if got := testutil.ToFloat64(notFound) - notFoundBefore; got != 2 {
+		t.Errorf("expected 2 requests with code 404, got %v", got)
+	}
+	if got := testutil.ToFloat64(failed) - failedBefore; got != 1 {
+		t.Errorf("expected 1 failed request, got %v", got)
+	}
+}